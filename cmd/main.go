@@ -2,18 +2,27 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rikw22/challenge-money/internal/common/database"
 	"github.com/rikw22/challenge-money/internal/common/health"
+	"github.com/rikw22/challenge-money/internal/common/idempotency"
 	"github.com/rikw22/challenge-money/internal/domain/account"
 	"github.com/rikw22/challenge-money/internal/domain/operationtype"
 	"github.com/rikw22/challenge-money/internal/domain/transaction"
+	"github.com/rikw22/challenge-money/pkg/httperrors"
 )
 
 var validate *validator.Validate
@@ -32,26 +41,88 @@ func main() {
 	}
 	defer dbPool.Close()
 
+	// Migrations
+	migrations, err := database.LoadMigrations(database.Migrations)
+	if err != nil {
+		log.Fatalf("Could not load migrations: %v", err)
+	}
+	migrator := database.NewMigrator(dbPool, migrations)
+
+	if len(os.Args) > 1 {
+		if os.Args[1] != "migrate" {
+			log.Fatalf("Unknown command %q, the only command is migrate", os.Args[1])
+		}
+		if err := migrate(context.Background(), migrator, dbPool, os.Args[2:]); err != nil {
+			log.Fatalf("Could not migrate database: %v", err)
+		}
+		return
+	}
+
+	if err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("Could not migrate database: %v", err)
+	}
+
+	unitOfWork := database.NewUnitOfWork(dbPool)
 	accountRepo := account.NewRepository(dbPool)
 	transactionRepo := transaction.NewRepository(dbPool)
 	operationtypeRepo := operationtype.NewRepository(dbPool)
+	idempotencyRepo := idempotency.NewRepository(dbPool)
+
+	// Idempotency
+	idempotencyTTL := 24 * time.Hour
+	if ttl := os.Getenv("IDEMPOTENCY_KEY_TTL"); ttl != "" {
+		idempotencyTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("Invalid IDEMPOTENCY_KEY_TTL: %v", err)
+		}
+	}
+	idempotencyMiddleware := idempotency.NewMiddleware(idempotencyRepo, idempotencyTTL)
+	go idempotencyMiddleware.PurgeExpired(context.Background(), time.Hour)
 
 	// HTTP
 	validate = validator.New()
+	// Report validation errors with the JSON names of the fields
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
 
 	healthHandler := health.NewHandler()
-	accountHandler := account.NewHandler(validate, accountRepo)
-	transactionHandler := transaction.NewHandler(validate, transactionRepo, accountRepo, operationtypeRepo)
+	accountHandler := account.NewHandler(validate, unitOfWork, accountRepo)
+	operationtypeHandler := operationtype.NewHandler(validate, operationtypeRepo)
+	transactionHandler := transaction.NewHandler(validate, unitOfWork, transactionRepo, accountRepo, operationtypeRepo)
+	go transactionHandler.SettleDueInstallments(context.Background(), time.Minute)
+
+	// Errors are written as problem details to clients asking for application/problem+json
+	render.Respond = httperrors.Respond
 
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
 	// Routes
 	r.Get("/health", healthHandler.Check)
-	r.Post("/accounts", accountHandler.Create)
+	r.With(idempotencyMiddleware.Handler).Post("/accounts", accountHandler.Create)
+	r.Get("/accounts", accountHandler.List)
 	r.Get("/accounts/{accountId}", accountHandler.Get)
-	r.Post("/transactions", transactionHandler.Create)
+	r.Patch("/accounts/{accountId}/status", accountHandler.UpdateStatus)
+	r.Get("/accounts/{accountId}/transactions", transactionHandler.List)
+	r.Get("/accounts/{accountId}/balance", transactionHandler.Balance)
+	r.Get("/accounts/{accountId}/statement", transactionHandler.Statement)
+	r.Get("/operation-types", operationtypeHandler.List)
+	r.With(idempotencyMiddleware.Handler).Post("/operation-types", operationtypeHandler.Create)
+	r.Get("/operation-types/{id}", operationtypeHandler.Get)
+	r.Post("/operation-types/{id}/deactivate", operationtypeHandler.Deactivate)
+	r.With(idempotencyMiddleware.Handler).Post("/transactions", transactionHandler.Create)
+	r.Get("/transactions/{id}", transactionHandler.Get)
+	r.Get("/transactions/{id}/settlements", transactionHandler.Settlements)
+	r.With(idempotencyMiddleware.Handler).Post("/transactions/{id}/reversal", transactionHandler.Reverse)
+	r.With(idempotencyMiddleware.Handler).Post("/transfers", transactionHandler.Transfer)
+	r.Get("/transfers/{id}", transactionHandler.GetTransfer)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -64,3 +135,49 @@ func main() {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
+
+// migrate runs the migrate command: up, down [steps], status or seed. Without arguments it applies
+// the pending migrations, as the server does when it starts.
+func migrate(ctx context.Context, migrator *database.Migrator, dbPool *pgxpool.Pool, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return migrator.Down(ctx, steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	case "baseline":
+		return migrator.Baseline(ctx)
+	case "seed":
+		if err := migrator.Up(ctx); err != nil {
+			return err
+		}
+		return database.ApplySeed(ctx, dbPool, database.Seed)
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down, status, baseline or seed", command)
+	}
+}
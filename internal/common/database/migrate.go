@@ -0,0 +1,344 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Migrations holds the schema migrations shipped with the binary, see LoadMigrations.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// Seed inserts sample accounts and transactions for local development.
+//
+//go:embed seed.sql
+var Seed string
+
+// migrationLockID is the advisory lock held while migrating, so that instances starting together
+// apply each migration once.
+const migrationLockID = 4823017659
+
+const createMigrationTable = `
+	CREATE TABLE IF NOT EXISTS schema_migration
+	(
+		version    BIGINT PRIMARY KEY,
+		name       VARCHAR(255) NOT NULL,
+		checksum   CHAR(64)     NOT NULL,
+		applied_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	ErrChecksumMismatch   = errors.New("applied migration was changed")
+	ErrUnknownMigration   = errors.New("applied migration is unknown to this binary")
+	ErrUnversionedSchema  = errors.New("database has a schema but no applied migrations")
+	ErrBaselineNotAllowed = errors.New("database cannot be baselined")
+)
+
+// Migration is a versioned change of the schema. Checksum is the SHA-256 of Up, recorded when the
+// migration is applied so that later edits to an applied migration are caught.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus is a known migration and when it was applied, nil while pending.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads the migrations of the migrations directory of fsys, ordered by version.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql, and every version needs both.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, file := range files {
+		match := migrationFileName.FindStringSubmatch(path.Base(file))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", file)
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", file, err)
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", file, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			migration.Up = string(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations, recording the applied ones in the schema_migration table.
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+func NewMigrator(db *pgxpool.Pool, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up applies the pending migrations in order, each one in its own database transaction. It fails
+// with ErrUnversionedSchema on databases created before migrations, which need Baseline first.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn, applied map[int64]MigrationStatus) error {
+		if len(applied) == 0 {
+			exists, err := schemaExists(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("%w, run `migrate baseline` if it was created by init.sql", ErrUnversionedSchema)
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "INSERT INTO schema_migration (version, name, checksum) VALUES ($1, $2, $3)",
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn, applied map[int64]MigrationStatus) error {
+		for _, migration := range slices.Backward(m.migrations) {
+			if steps == 0 {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migration WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("Rolled back migration %d_%s", migration.Version, migration.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// Baseline records the initial migration as applied without running it, for databases created by
+// the former init.sql, which the initial migration is. The later migrations, which hold every change
+// made since, are then applied by Up.
+func (m *Migrator) Baseline(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn, applied map[int64]MigrationStatus) error {
+		exists, err := schemaExists(ctx, conn)
+		if err != nil {
+			return err
+		}
+		migration, err := baseline(m.migrations, applied, exists)
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Exec(ctx, "INSERT INTO schema_migration (version, name, checksum) VALUES ($1, $2, $3)",
+			migration.Version, migration.Name, migration.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to baseline migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Baselined migration %d_%s", migration.Version, migration.Name)
+		return nil
+	})
+}
+
+// baseline returns the migration to record as applied by Baseline, the initial one, when the
+// database has a schema and no applied migrations.
+func baseline(migrations []Migration, applied map[int64]MigrationStatus, schemaExists bool) (Migration, error) {
+	switch {
+	case len(migrations) == 0:
+		return Migration{}, fmt.Errorf("%w: there are no migrations", ErrBaselineNotAllowed)
+	case len(applied) > 0:
+		return Migration{}, fmt.Errorf("%w: migrations were already applied", ErrBaselineNotAllowed)
+	case !schemaExists:
+		return Migration{}, fmt.Errorf("%w: there is no schema, run `migrate up` instead", ErrBaselineNotAllowed)
+	}
+	return migrations[0], nil
+}
+
+// Status returns every known migration with when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, applied map[int64]MigrationStatus) error {
+		for _, migration := range m.migrations {
+			status := applied[migration.Version]
+			status.Migration = migration
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn holding the migration lock, with the applied migrations once their checksums
+// are verified against the known ones.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn, applied map[int64]MigrationStatus) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	// Session-level lock, held across the transactions of each migration
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if _, err := conn.Exec(ctx, createMigrationTable); err != nil {
+		return fmt.Errorf("failed to create migration table: %w", err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := verify(m.migrations, applied); err != nil {
+		return err
+	}
+
+	return fn(conn, applied)
+}
+
+// schemaExists tells whether the tables of the initial migration exist, looking for the account table.
+func schemaExists(ctx context.Context, conn *pgxpool.Conn) (bool, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass('account') IS NOT NULL").Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look for an existing schema: %w", err)
+	}
+	return exists, nil
+}
+
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]MigrationStatus, error) {
+	rows, err := conn.Query(ctx, "SELECT version, name, checksum, applied_at FROM schema_migration")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]MigrationStatus{}
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&status.Version, &status.Name, &status.Checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		status.AppliedAt = &appliedAt
+		applied[status.Version] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// verify returns ErrUnknownMigration when a migration was applied by a newer binary, and
+// ErrChecksumMismatch when an applied migration was edited afterwards.
+func verify(migrations []Migration, applied map[int64]MigrationStatus) error {
+	known := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, status := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: %d_%s", ErrUnknownMigration, version, status.Name)
+		}
+		if migration.Checksum != status.Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, version, status.Name)
+		}
+	}
+	return nil
+}
+
+// ApplySeed runs the seed script in a single database transaction. It fails without changes when
+// the sample rows already exist.
+func ApplySeed(ctx context.Context, db *pgxpool.Pool, seed string) error {
+	err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, seed)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply seed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name             string
+		files            fstest.MapFS
+		expectedVersions []int64
+		expectedErr      string
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"migrations/0010_add_index.up.sql":        {Data: []byte("CREATE INDEX a_idx ON a (b);")},
+				"migrations/0010_add_index.down.sql":      {Data: []byte("DROP INDEX a_idx;")},
+				"migrations/0002_create_table.up.sql":     {Data: []byte("CREATE TABLE a (b INTEGER);")},
+				"migrations/0002_create_table.down.sql":   {Data: []byte("DROP TABLE a;")},
+				"migrations/0001_initial_schema.up.sql":   {Data: []byte("SELECT 1;")},
+				"migrations/0001_initial_schema.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			expectedVersions: []int64{1, 2, 10},
+		},
+		{
+			name:             "no migrations",
+			files:            fstest.MapFS{},
+			expectedVersions: []int64{},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"migrations/0001_initial_schema.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			expectedErr: "needs both an up and a down file",
+		},
+		{
+			name: "missing up file",
+			files: fstest.MapFS{
+				"migrations/0001_initial_schema.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			expectedErr: "needs both an up and a down file",
+		},
+		{
+			name: "invalid file name",
+			files: fstest.MapFS{
+				"migrations/initial_schema.sql": {Data: []byte("SELECT 1;")},
+			},
+			expectedErr: "invalid migration file name",
+		},
+		{
+			name: "version with two names",
+			files: fstest.MapFS{
+				"migrations/0001_initial_schema.up.sql": {Data: []byte("SELECT 1;")},
+				"migrations/0001_other_schema.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			expectedErr: "has two names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tt.files)
+
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(migrations) != len(tt.expectedVersions) {
+				t.Fatalf("expected %d migrations, got %d", len(tt.expectedVersions), len(migrations))
+			}
+			for i, migration := range migrations {
+				if migration.Version != tt.expectedVersions[i] {
+					t.Errorf("migration %d: expected version %d, got %d", i, tt.expectedVersions[i], migration.Version)
+				}
+				if len(migration.Checksum) != 64 {
+					t.Errorf("migration %d: expected a SHA-256 checksum, got %q", i, migration.Checksum)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := LoadMigrations(Migrations)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "initial_schema" {
+		t.Fatalf("expected the initial schema as the first migration, got %+v", migrations)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "initial_schema", Checksum: "aaa"},
+		{Version: 2, Name: "create_table", Checksum: "bbb"},
+	}
+	appliedAt := time.Now()
+
+	tests := []struct {
+		name        string
+		applied     map[int64]MigrationStatus
+		expectedErr error
+	}{
+		{
+			name: "applied migrations unchanged",
+			applied: map[int64]MigrationStatus{
+				1: {Migration: Migration{Version: 1, Name: "initial_schema", Checksum: "aaa"}, AppliedAt: &appliedAt},
+			},
+		},
+		{
+			name:    "nothing applied",
+			applied: map[int64]MigrationStatus{},
+		},
+		{
+			name: "applied migration changed",
+			applied: map[int64]MigrationStatus{
+				1: {Migration: Migration{Version: 1, Name: "initial_schema", Checksum: "aaa"}, AppliedAt: &appliedAt},
+				2: {Migration: Migration{Version: 2, Name: "create_table", Checksum: "ccc"}, AppliedAt: &appliedAt},
+			},
+			expectedErr: ErrChecksumMismatch,
+		},
+		{
+			name: "applied migration unknown",
+			applied: map[int64]MigrationStatus{
+				3: {Migration: Migration{Version: 3, Name: "add_index", Checksum: "ddd"}, AppliedAt: &appliedAt},
+			},
+			expectedErr: ErrUnknownMigration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verify(migrations, tt.applied)
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "initial_schema", Checksum: "aaa"},
+		{Version: 2, Name: "create_table", Checksum: "bbb"},
+	}
+	appliedAt := time.Now()
+
+	tests := []struct {
+		name            string
+		migrations      []Migration
+		applied         map[int64]MigrationStatus
+		schemaExists    bool
+		expectedVersion int64
+		expectedErr     error
+	}{
+		{
+			name:            "schema without applied migrations",
+			migrations:      migrations,
+			applied:         map[int64]MigrationStatus{},
+			schemaExists:    true,
+			expectedVersion: 1,
+		},
+		{
+			name:       "migrations already applied",
+			migrations: migrations,
+			applied: map[int64]MigrationStatus{
+				1: {Migration: Migration{Version: 1, Name: "initial_schema", Checksum: "aaa"}, AppliedAt: &appliedAt},
+			},
+			schemaExists: true,
+			expectedErr:  ErrBaselineNotAllowed,
+		},
+		{
+			name:        "empty database",
+			migrations:  migrations,
+			applied:     map[int64]MigrationStatus{},
+			expectedErr: ErrBaselineNotAllowed,
+		},
+		{
+			name:         "no migrations",
+			applied:      map[int64]MigrationStatus{},
+			schemaExists: true,
+			expectedErr:  ErrBaselineNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migration, err := baseline(tt.migrations, tt.applied, tt.schemaExists)
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err == nil && migration.Version != tt.expectedVersion {
+				t.Errorf("expected migration %d, got %d", tt.expectedVersion, migration.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE transaction;
+DROP TABLE operationtype;
+DROP TABLE account;
@@ -1,4 +1,5 @@
--- DDL
+-- The schema of the init.sql shipped before migrations, which databases created by it are
+-- baselined at. Its sample accounts and transactions are kept in seed.sql
 CREATE TABLE account
 (
     ID              INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
@@ -22,14 +23,6 @@ CREATE TABLE transaction
     eventdate        TIMESTAMP
 );
 
-
--- DML - Initial data
--- Account
-INSERT INTO account
-VALUES (1, '11111111111'),
-       (2, '22222222222');
-SELECT setval(pg_get_serial_sequence('account', 'id'), (SELECT MAX(id) FROM account));
-
 -- Operation Types
 INSERT INTO operationtype
 VALUES (1, 'Normal Purchase'),
@@ -37,11 +30,3 @@ VALUES (1, 'Normal Purchase'),
        (3, 'Withdrawal'),
        (4, 'Credit Voucher');
 SELECT setval(pg_get_serial_sequence('operationtype', 'id'), (SELECT MAX(id) FROM operationtype));
-
--- Transaction
-INSERT INTO transaction (ID, account_id, operationtype_id, amount, balance, eventdate)
-VALUES (uuidv7(), 1, 1, -5000, 0, '2020-01-01T10:32:07.7199222'),
-       (uuidv7(), 1, 1, -2350, -1350, '2020-01-01T10:32:08.7199222'),
-       (uuidv7(), 1, 1, -1870, -1870, '2020-01-01T10:32:09.7199222'),
-       (uuidv7(), 1, 4, 6000, 0, '2020-01-01T10:32:10.7199222');
-
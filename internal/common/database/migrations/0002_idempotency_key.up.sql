@@ -0,0 +1,12 @@
+CREATE TABLE idempotency_key
+(
+    scope         VARCHAR(100),
+    key           VARCHAR(255),
+    request_hash  CHAR(64),
+    status_code   INTEGER,
+    content_type  VARCHAR(100),
+    response_body BYTEA,
+    created_at    TIMESTAMP,
+    expires_at    TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
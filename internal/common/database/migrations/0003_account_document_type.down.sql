@@ -0,0 +1,3 @@
+ALTER TABLE account
+    DROP COLUMN document_type,
+    ALTER COLUMN document_number TYPE VARCHAR(11);
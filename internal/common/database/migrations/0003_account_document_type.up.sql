@@ -0,0 +1,6 @@
+-- Accounts hold either a CPF (11 digits) or a CNPJ (14 digits)
+ALTER TABLE account
+    ALTER COLUMN document_number TYPE VARCHAR(14),
+    ADD COLUMN document_type VARCHAR(4);
+
+UPDATE account SET document_type = CASE WHEN length(document_number) = 14 THEN 'CNPJ' ELSE 'CPF' END;
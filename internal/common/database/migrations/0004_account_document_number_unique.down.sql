@@ -0,0 +1 @@
+ALTER TABLE account DROP CONSTRAINT account_document_number_key;
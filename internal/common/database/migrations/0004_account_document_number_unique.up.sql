@@ -0,0 +1,2 @@
+ALTER TABLE account
+    ADD CONSTRAINT account_document_number_key UNIQUE (document_number);
@@ -0,0 +1,3 @@
+ALTER TABLE transaction DROP COLUMN currency;
+
+ALTER TABLE account DROP COLUMN currency;
@@ -0,0 +1,6 @@
+-- Existing accounts and transactions are in the default currency
+ALTER TABLE account
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
+
+ALTER TABLE transaction
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
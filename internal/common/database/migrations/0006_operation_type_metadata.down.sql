@@ -0,0 +1,4 @@
+ALTER TABLE operationtype
+    DROP COLUMN active,
+    DROP COLUMN discharges_balance,
+    DROP COLUMN direction;
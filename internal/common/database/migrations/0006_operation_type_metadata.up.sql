@@ -0,0 +1,11 @@
+-- Transactions are processed from the metadata of their operation type rather than its id. The
+-- credit voucher (4) is the only credit, as it was the only type taken as positive before
+ALTER TABLE operationtype
+    ADD COLUMN direction          VARCHAR(6) CHECK (direction IN ('debit', 'credit')),
+    ADD COLUMN discharges_balance BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN active             BOOLEAN NOT NULL DEFAULT TRUE;
+
+UPDATE operationtype SET direction = CASE WHEN ID = 4 THEN 'credit' ELSE 'debit' END, discharges_balance = (ID = 4);
+
+ALTER TABLE operationtype
+    ALTER COLUMN direction SET NOT NULL;
@@ -0,0 +1,6 @@
+ALTER TABLE transaction
+    DROP COLUMN installment_number,
+    DROP COLUMN installments,
+    DROP COLUMN parent_id;
+
+ALTER TABLE operationtype DROP COLUMN allows_installments;
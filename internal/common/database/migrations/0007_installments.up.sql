@@ -0,0 +1,11 @@
+-- Installment purchases are stored as a parent row holding the total, with a zero balance,
+-- and one child row per installment whose eventdate is its due date
+ALTER TABLE operationtype
+    ADD COLUMN allows_installments BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE operationtype SET allows_installments = TRUE WHERE ID = 2;
+
+ALTER TABLE transaction
+    ADD COLUMN parent_id          UUID REFERENCES transaction (ID),
+    ADD COLUMN installments       INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN installment_number INTEGER;
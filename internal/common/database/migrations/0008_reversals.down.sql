@@ -0,0 +1 @@
+ALTER TABLE transaction DROP COLUMN reversal_of;
@@ -0,0 +1,3 @@
+-- Reversals reference the transaction they compensate
+ALTER TABLE transaction
+    ADD COLUMN reversal_of UUID REFERENCES transaction (ID);
@@ -0,0 +1,4 @@
+ALTER TABLE account
+    DROP CONSTRAINT account_available_limit_check,
+    DROP COLUMN available_limit,
+    DROP COLUMN credit_limit;
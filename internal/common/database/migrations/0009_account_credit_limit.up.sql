@@ -0,0 +1,5 @@
+-- Existing accounts start without credit limit
+ALTER TABLE account
+    ADD COLUMN credit_limit    INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN available_limit INTEGER NOT NULL DEFAULT 0,
+    ADD CONSTRAINT account_available_limit_check CHECK (available_limit BETWEEN 0 AND credit_limit);
@@ -0,0 +1,13 @@
+ALTER TABLE account
+    ADD COLUMN status VARCHAR(7) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'blocked', 'closed'));
+
+-- Audit trail of the account status changes
+CREATE TABLE account_status_history
+(
+    ID          INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    account_id  INTEGER      NOT NULL REFERENCES account (ID),
+    from_status VARCHAR(7)   NOT NULL,
+    to_status   VARCHAR(7)   NOT NULL,
+    reason      VARCHAR(255) NOT NULL,
+    changed_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
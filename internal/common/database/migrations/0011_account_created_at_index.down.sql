@@ -0,0 +1 @@
+DROP INDEX account_created_at_idx;
@@ -0,0 +1,2 @@
+-- Keyset pagination of the account search by creation date
+CREATE INDEX account_created_at_idx ON account (created_at, ID);
@@ -0,0 +1,5 @@
+DELETE FROM operationtype WHERE ID = 5;
+
+ALTER TABLE transaction DROP COLUMN transfer_id;
+
+DROP TABLE transfer;
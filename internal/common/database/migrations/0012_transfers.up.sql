@@ -0,0 +1,18 @@
+-- A transfer moves credit between accounts with a debit on the source and a credit on the
+-- destination, both referencing it through transfer_id
+CREATE TABLE transfer
+(
+    ID                     UUID PRIMARY KEY DEFAULT uuidv7(),
+    source_account_id      INTEGER   NOT NULL REFERENCES account (ID),
+    destination_account_id INTEGER   NOT NULL REFERENCES account (ID),
+    amount                 INTEGER   NOT NULL,
+    currency               CHAR(3)   NOT NULL,
+    created_at             TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE transaction
+    ADD COLUMN transfer_id UUID REFERENCES transfer (ID);
+
+INSERT INTO operationtype (ID, description, direction, discharges_balance, allows_installments)
+VALUES (5, 'Transfer', 'debit', FALSE, FALSE);
+SELECT setval(pg_get_serial_sequence('operationtype', 'id'), (SELECT MAX(id) FROM operationtype));
@@ -0,0 +1,5 @@
+DROP VIEW journal_balance;
+DROP TABLE posting;
+DROP TABLE journal_entry;
+DROP FUNCTION check_journal_entry_balanced();
+DROP FUNCTION reject_journal_change();
@@ -0,0 +1,79 @@
+-- Append-only journal behind the transaction balances, which the balance column caches. Every
+-- entry has postings summing to zero: a transaction entry opens the balance of a new transaction
+-- against the external ledger, and a settlement entry moves an amount from the balance of a
+-- credit to the balance of the debt it pays off
+CREATE TABLE journal_entry
+(
+    ID         UUID PRIMARY KEY DEFAULT uuidv7(),
+    kind       VARCHAR(11) NOT NULL CHECK (kind IN ('transaction', 'settlement')),
+    created_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE posting
+(
+    ID               UUID PRIMARY KEY DEFAULT uuidv7(),
+    journal_entry_id UUID       NOT NULL REFERENCES journal_entry (ID),
+    ledger           VARCHAR(8) NOT NULL CHECK (ledger IN ('balance', 'external')),
+    transaction_id   UUID       NOT NULL REFERENCES transaction (ID),
+    amount           INTEGER    NOT NULL
+);
+
+CREATE INDEX posting_journal_entry_id_idx ON posting (journal_entry_id);
+CREATE INDEX posting_transaction_id_idx ON posting (transaction_id);
+
+CREATE FUNCTION reject_journal_change() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_entry_append_only
+    BEFORE UPDATE OR DELETE
+    ON journal_entry
+    FOR EACH ROW
+EXECUTE FUNCTION reject_journal_change();
+
+CREATE TRIGGER posting_append_only
+    BEFORE UPDATE OR DELETE
+    ON posting
+    FOR EACH ROW
+EXECUTE FUNCTION reject_journal_change();
+
+-- Checked when the database transaction commits, once all the postings of the entry are in
+CREATE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF (SELECT SUM(amount) FROM posting WHERE journal_entry_id = NEW.journal_entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.journal_entry_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER posting_balanced
+    AFTER INSERT
+    ON posting
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+EXECUTE FUNCTION check_journal_entry_balanced();
+
+-- Transaction balances rebuilt from the journal, they always match transaction.balance
+CREATE VIEW journal_balance AS
+SELECT transaction_id, SUM(amount) AS balance
+FROM posting
+WHERE ledger = 'balance'
+GROUP BY transaction_id;
+
+-- Existing transactions are opened at their current balance, as what settled them was not
+-- recorded, each by an entry sharing its id
+INSERT INTO journal_entry (ID, kind, created_at)
+SELECT ID, 'transaction', COALESCE(eventdate, CURRENT_TIMESTAMP)
+FROM transaction
+WHERE balance <> 0;
+
+INSERT INTO posting (journal_entry_id, ledger, transaction_id, amount)
+SELECT t.ID, p.ledger, t.ID, p.amount
+FROM transaction t,
+     LATERAL (VALUES ('balance', t.balance), ('external', -t.balance)) AS p(ledger, amount)
+WHERE t.balance <> 0;
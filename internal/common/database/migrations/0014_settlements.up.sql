@@ -0,0 +1,19 @@
+-- What each settlement entry applied, from which payment to which debt
+CREATE TABLE settlement
+(
+    ID               UUID PRIMARY KEY DEFAULT uuidv7(),
+    journal_entry_id UUID      NOT NULL REFERENCES journal_entry (ID),
+    payment_id       UUID      NOT NULL REFERENCES transaction (ID),
+    debit_id         UUID      NOT NULL REFERENCES transaction (ID),
+    amount           INTEGER   NOT NULL CHECK (amount > 0),
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX settlement_payment_id_idx ON settlement (payment_id);
+CREATE INDEX settlement_debit_id_idx ON settlement (debit_id);
+
+CREATE TRIGGER settlement_append_only
+    BEFORE UPDATE OR DELETE
+    ON settlement
+    FOR EACH ROW
+EXECUTE FUNCTION reject_journal_change();
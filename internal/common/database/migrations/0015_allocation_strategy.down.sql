@@ -0,0 +1,5 @@
+ALTER TABLE operationtype
+    DROP COLUMN allocation_strategy,
+    DROP COLUMN interest_rate;
+
+ALTER TABLE account DROP COLUMN allocation_strategy;
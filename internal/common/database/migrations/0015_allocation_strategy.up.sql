@@ -0,0 +1,16 @@
+-- How payments are split among the debts of the account, oldest first when NULL
+ALTER TABLE account
+    ADD COLUMN allocation_strategy VARCHAR(22) CHECK (allocation_strategy IN
+                                                      ('oldest_first', 'withdrawals_first', 'highest_interest_first', 'proportional'));
+
+-- interest_rate is the monthly rate in basis points, ranking the debts for the
+-- highest_interest_first strategy. allocation_strategy overrides the strategy of the account for
+-- the payments of a discharging type
+ALTER TABLE operationtype
+    ADD COLUMN interest_rate       INTEGER NOT NULL DEFAULT 0 CHECK (interest_rate >= 0),
+    ADD COLUMN allocation_strategy VARCHAR(22) CHECK (allocation_strategy IN
+                                                      ('oldest_first', 'withdrawals_first', 'highest_interest_first', 'proportional'));
+
+UPDATE operationtype SET interest_rate = 1200 WHERE ID IN (1, 5);
+UPDATE operationtype SET interest_rate = 900 WHERE ID = 2;
+UPDATE operationtype SET interest_rate = 1500 WHERE ID = 3;
@@ -0,0 +1,3 @@
+DELETE FROM operationtype WHERE kind = 'transfer' AND direction = 'credit';
+
+ALTER TABLE operationtype DROP COLUMN kind;
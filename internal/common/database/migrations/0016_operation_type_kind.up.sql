@@ -0,0 +1,13 @@
+-- What each operation type is used for, so that transfers and allocation strategies find the types
+-- they rely on by kind rather than by id
+ALTER TABLE operationtype
+    ADD COLUMN kind VARCHAR(10) CHECK (kind IN ('purchase', 'withdrawal', 'payment', 'transfer'));
+
+UPDATE operationtype SET kind = 'purchase' WHERE ID IN (1, 2);
+UPDATE operationtype SET kind = 'withdrawal' WHERE ID = 3;
+UPDATE operationtype SET kind = 'payment' WHERE ID = 4;
+UPDATE operationtype SET kind = 'transfer' WHERE ID = 5;
+
+-- The credit side of transfers, which used to be a credit voucher
+INSERT INTO operationtype (description, direction, discharges_balance, allows_installments, interest_rate, kind)
+VALUES ('Transfer Received', 'credit', TRUE, FALSE, 0, 'transfer');
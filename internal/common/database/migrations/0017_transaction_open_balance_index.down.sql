@@ -0,0 +1 @@
+DROP INDEX transaction_open_balance_idx;
@@ -0,0 +1,3 @@
+-- Transactions with an open balance, looked up by account and currency when settling debts and
+-- by the job settling the installments that fall due
+CREATE INDEX transaction_open_balance_idx ON transaction (account_id, currency) WHERE balance <> 0;
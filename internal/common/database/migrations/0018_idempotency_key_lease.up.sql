@@ -0,0 +1,5 @@
+-- Lease of the request holding an in-progress key, which a retry may take over once it lapses.
+-- Keys left in progress before the lease existed are leased until they expire.
+ALTER TABLE idempotency_key ADD COLUMN locked_until TIMESTAMP;
+
+UPDATE idempotency_key SET locked_until = expires_at WHERE status_code IS NULL;
@@ -0,0 +1,47 @@
+-- Sample accounts and transactions for local development, applied with `migrate seed`
+
+-- Account
+-- The available limit of account 1 discounts the debt of its seeded transactions
+INSERT INTO account (ID, document_number, document_type, credit_limit, available_limit)
+VALUES (1, '52998224725', 'CPF', 500000, 496780),
+       (2, '11222333000181', 'CNPJ', 500000, 500000);
+SELECT setval(pg_get_serial_sequence('account', 'id'), (SELECT MAX(id) FROM account));
+
+-- Transaction
+INSERT INTO transaction (ID, account_id, operationtype_id, amount, balance, eventdate)
+VALUES ('019a096b-ad9f-7f0e-88a4-9c93a754b029', 1, 1, -5000, 0, '2020-01-01T10:32:07.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754b02a', 1, 1, -2350, -1350, '2020-01-01T10:32:08.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754b02b', 1, 1, -1870, -1870, '2020-01-01T10:32:09.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754b02c', 1, 4, 6000, 0, '2020-01-01T10:32:10.7199222');
+
+-- Journal of the seeded transactions, where the credit voucher pays off the first purchase and
+-- part of the second
+INSERT INTO journal_entry (ID, kind, created_at)
+VALUES ('019a096b-ad9f-7f0e-88a4-9c93a754c001', 'transaction', '2020-01-01T10:32:07.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c002', 'transaction', '2020-01-01T10:32:08.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c003', 'transaction', '2020-01-01T10:32:09.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c004', 'transaction', '2020-01-01T10:32:10.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c005', 'settlement', '2020-01-01T10:32:10.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c006', 'settlement', '2020-01-01T10:32:10.7199222');
+
+INSERT INTO posting (journal_entry_id, ledger, transaction_id, amount)
+VALUES ('019a096b-ad9f-7f0e-88a4-9c93a754c001', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b029', -5000),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c001', 'external', '019a096b-ad9f-7f0e-88a4-9c93a754b029', 5000),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c002', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b02a', -2350),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c002', 'external', '019a096b-ad9f-7f0e-88a4-9c93a754b02a', 2350),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c003', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b02b', -1870),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c003', 'external', '019a096b-ad9f-7f0e-88a4-9c93a754b02b', 1870),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c004', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b02c', 6000),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c004', 'external', '019a096b-ad9f-7f0e-88a4-9c93a754b02c', -6000),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c005', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b029', 5000),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c005', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b02c', -5000),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c006', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b02a', 1000),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754c006', 'balance', '019a096b-ad9f-7f0e-88a4-9c93a754b02c', -1000);
+
+INSERT INTO settlement (ID, journal_entry_id, payment_id, debit_id, amount, created_at)
+VALUES ('019a096b-ad9f-7f0e-88a4-9c93a754d001', '019a096b-ad9f-7f0e-88a4-9c93a754c005',
+        '019a096b-ad9f-7f0e-88a4-9c93a754b02c', '019a096b-ad9f-7f0e-88a4-9c93a754b029', 5000,
+        '2020-01-01T10:32:10.7199222'),
+       ('019a096b-ad9f-7f0e-88a4-9c93a754d002', '019a096b-ad9f-7f0e-88a4-9c93a754c006',
+        '019a096b-ad9f-7f0e-88a4-9c93a754b02c', '019a096b-ad9f-7f0e-88a4-9c93a754b02a', 1000,
+        '2020-01-01T10:32:10.7199222');
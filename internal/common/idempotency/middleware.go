@@ -0,0 +1,158 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/rikw22/challenge-money/pkg/httperrors"
+)
+
+const (
+	// HeaderKey is the request header carrying the client supplied idempotency key.
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed is set on responses replayed from a stored record.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+
+	// lockLease bounds how long a request that never finished, such as one lost in a crash, keeps
+	// its key in progress before a retry can take it over.
+	lockLease = time.Minute
+)
+
+type Middleware struct {
+	repository Repository
+	ttl        time.Duration
+}
+
+func NewMiddleware(repository Repository, ttl time.Duration) *Middleware {
+	return &Middleware{
+		repository: repository,
+		ttl:        ttl,
+	}
+}
+
+// Handler makes requests carrying an Idempotency-Key safe to retry. The first request with a
+// key is processed and its response stored, repeats with the same body replay that response
+// and repeats with a different body are rejected. Keys expire after the configured window.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("idempotency key must have at most 255 characters")))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			render.Render(w, r, httperrors.ErrInvalidRequest(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		hash := sha256.Sum256(body)
+		record := Record{
+			Scope:       r.Method + " " + r.URL.Path,
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(m.ttl),
+			LockedUntil: now.Add(lockLease),
+		}
+
+		existing, reserved, err := m.repository.Reserve(r.Context(), &record)
+		if err != nil {
+			render.Render(w, r, httperrors.ErrInternalServer(err))
+			return
+		}
+
+		if !reserved {
+			switch {
+			case existing.RequestHash != record.RequestHash:
+				render.Render(w, r, httperrors.ErrUnprocessableEntity(errors.New("idempotency key was already used with a different request payload")).WithCode(httperrors.CodeIdempotencyKeyReused))
+			case !existing.Completed():
+				render.Render(w, r, httperrors.ErrConflict(errors.New("a request with this idempotency key is still being processed")).WithCode(httperrors.CodeIdempotencyKeyInProgress))
+			default:
+				replay(w, existing)
+			}
+			return
+		}
+
+		// Failed requests give up the key so the client can retry them
+		ctx := context.WithoutCancel(r.Context())
+		release := func() {
+			if err := m.repository.Release(ctx, record.Scope, record.Key); err != nil {
+				log.Printf("Could not release idempotency key %q: %v", record.Key, err)
+			}
+		}
+
+		var response bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&response)
+
+		// A panicking handler fails the request too, left to the recoverer once the key is released
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+
+		if ww.Status() >= http.StatusInternalServerError {
+			release()
+			return
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if err := m.repository.Complete(ctx, record.Scope, record.Key, status, ww.Header().Get("Content-Type"), response.Bytes()); err != nil {
+			log.Printf("Could not store response for idempotency key %q: %v", record.Key, err)
+		}
+	})
+}
+
+// PurgeExpired deletes expired keys every interval until ctx is done.
+func (m *Middleware) PurgeExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := m.repository.DeleteExpired(ctx, now); err != nil {
+				log.Printf("Could not purge expired idempotency keys: %v", err)
+			}
+		}
+	}
+}
+
+func replay(w http.ResponseWriter, record Record) {
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(record.ResponseBody)))
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.ResponseBody)
+}
@@ -0,0 +1,262 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockRepository struct {
+	reserveFunc  func(ctx context.Context, record *Record) (Record, bool, error)
+	completeFunc func(ctx context.Context, scope string, key string, statusCode int, contentType string, body []byte) error
+	releaseFunc  func(ctx context.Context, scope string, key string) error
+}
+
+func (m *mockRepository) Reserve(ctx context.Context, record *Record) (Record, bool, error) {
+	if m.reserveFunc != nil {
+		return m.reserveFunc(ctx, record)
+	}
+	return Record{}, false, errors.New("not implemented")
+}
+
+func (m *mockRepository) Complete(ctx context.Context, scope string, key string, statusCode int, contentType string, body []byte) error {
+	if m.completeFunc != nil {
+		return m.completeFunc(ctx, scope, key, statusCode, contentType, body)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) Release(ctx context.Context, scope string, key string) error {
+	if m.releaseFunc != nil {
+		return m.releaseFunc(ctx, scope, key)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, errors.New("not implemented")
+}
+
+// otherRequestHash stands for the hash of a payload different from the one sent in the tests
+const otherRequestHash = "4ebe6d4e2b2b3ac3bb4c7e6f3a31e2e2f96c4c76e2b3e6c0d1f1e7c9a6bdf0c1"
+
+func TestMiddleware_Handler(t *testing.T) {
+	tests := []struct {
+		name             string
+		key              string
+		setupMock        func(*mockRepository, *[]string)
+		handlerStatus    int
+		expectedStatus   int
+		expectedBody     string
+		expectedCalls    []string
+		expectHandlerRun bool
+	}{
+		{
+			name:             "request without key is passed through",
+			key:              "",
+			setupMock:        nil,
+			handlerStatus:    http.StatusCreated,
+			expectedStatus:   http.StatusCreated,
+			expectedBody:     `{"id":1}`,
+			expectedCalls:    nil,
+			expectHandlerRun: true,
+		},
+		{
+			name:             "key too long",
+			key:              strings.Repeat("k", 256),
+			setupMock:        nil,
+			expectedStatus:   http.StatusBadRequest,
+			expectedCalls:    nil,
+			expectHandlerRun: false,
+		},
+		{
+			name: "first request stores the response",
+			key:  "key-1",
+			setupMock: func(m *mockRepository, calls *[]string) {
+				m.reserveFunc = func(ctx context.Context, record *Record) (Record, bool, error) {
+					*calls = append(*calls, "reserve")
+					if !record.LockedUntil.Equal(record.CreatedAt.Add(lockLease)) {
+						return Record{}, false, errors.New("unexpected lease")
+					}
+					return *record, true, nil
+				}
+				m.completeFunc = func(ctx context.Context, scope string, key string, statusCode int, contentType string, body []byte) error {
+					*calls = append(*calls, "complete")
+					if scope != "POST /transactions" || statusCode != http.StatusCreated || string(body) != `{"id":1}` {
+						return errors.New("unexpected response stored")
+					}
+					return nil
+				}
+			},
+			handlerStatus:    http.StatusCreated,
+			expectedStatus:   http.StatusCreated,
+			expectedBody:     `{"id":1}`,
+			expectedCalls:    []string{"reserve", "complete"},
+			expectHandlerRun: true,
+		},
+		{
+			name: "repeated request replays the stored response",
+			key:  "key-1",
+			setupMock: func(m *mockRepository, calls *[]string) {
+				m.reserveFunc = func(ctx context.Context, record *Record) (Record, bool, error) {
+					*calls = append(*calls, "reserve")
+					return Record{
+						RequestHash:  record.RequestHash,
+						StatusCode:   http.StatusCreated,
+						ContentType:  "application/json",
+						ResponseBody: []byte(`{"id":1}`),
+					}, false, nil
+				}
+			},
+			expectedStatus:   http.StatusCreated,
+			expectedBody:     `{"id":1}`,
+			expectedCalls:    []string{"reserve"},
+			expectHandlerRun: false,
+		},
+		{
+			name: "repeated request with different payload",
+			key:  "key-1",
+			setupMock: func(m *mockRepository, calls *[]string) {
+				m.reserveFunc = func(ctx context.Context, record *Record) (Record, bool, error) {
+					*calls = append(*calls, "reserve")
+					return Record{
+						RequestHash:  otherRequestHash,
+						StatusCode:   http.StatusCreated,
+						ResponseBody: []byte(`{"id":1}`),
+					}, false, nil
+				}
+			},
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedCalls:    []string{"reserve"},
+			expectHandlerRun: false,
+		},
+		{
+			name: "repeated request while the first is in progress",
+			key:  "key-1",
+			setupMock: func(m *mockRepository, calls *[]string) {
+				m.reserveFunc = func(ctx context.Context, record *Record) (Record, bool, error) {
+					*calls = append(*calls, "reserve")
+					return Record{RequestHash: record.RequestHash}, false, nil
+				}
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedCalls:    []string{"reserve"},
+			expectHandlerRun: false,
+		},
+		{
+			name: "failed request releases the key",
+			key:  "key-1",
+			setupMock: func(m *mockRepository, calls *[]string) {
+				m.reserveFunc = func(ctx context.Context, record *Record) (Record, bool, error) {
+					*calls = append(*calls, "reserve")
+					return *record, true, nil
+				}
+				m.releaseFunc = func(ctx context.Context, scope string, key string) error {
+					*calls = append(*calls, "release")
+					return nil
+				}
+			},
+			handlerStatus:    http.StatusInternalServerError,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedCalls:    []string{"reserve", "release"},
+			expectHandlerRun: true,
+		},
+		{
+			name: "repository error",
+			key:  "key-1",
+			setupMock: func(m *mockRepository, calls *[]string) {
+				m.reserveFunc = func(ctx context.Context, record *Record) (Record, bool, error) {
+					*calls = append(*calls, "reserve")
+					return Record{}, false, errors.New("database error")
+				}
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedCalls:    []string{"reserve"},
+			expectHandlerRun: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			mockRepo := &mockRepository{}
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo, &calls)
+			}
+
+			handlerRun := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerRun = true
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.handlerStatus)
+				w.Write([]byte(`{"id":1}`))
+			})
+
+			handler := NewMiddleware(mockRepo, time.Hour).Handler(next)
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{"amount":2}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				req.Header.Set(HeaderKey, tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %s, got %s", tt.expectedBody, w.Body.String())
+			}
+
+			if handlerRun != tt.expectHandlerRun {
+				t.Errorf("expected handler run to be %v, got %v", tt.expectHandlerRun, handlerRun)
+			}
+
+			if strings.Join(calls, ",") != strings.Join(tt.expectedCalls, ",") {
+				t.Errorf("expected repository calls %v, got %v", tt.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Handler_Panic(t *testing.T) {
+	var calls []string
+	mockRepo := &mockRepository{
+		reserveFunc: func(ctx context.Context, record *Record) (Record, bool, error) {
+			calls = append(calls, "reserve")
+			return *record, true, nil
+		},
+		releaseFunc: func(ctx context.Context, scope string, key string) error {
+			calls = append(calls, "release")
+			return nil
+		},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	})
+
+	handler := NewMiddleware(mockRepo, time.Hour).Handler(next)
+
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{"amount":2}`))
+	req.Header.Set(HeaderKey, "key-1")
+	w := httptest.NewRecorder()
+
+	defer func() {
+		if p := recover(); p != "handler failed" {
+			t.Errorf("expected the panic to be passed on, got %v", p)
+		}
+		if strings.Join(calls, ",") != "reserve,release" {
+			t.Errorf("expected repository calls [reserve release], got %v", calls)
+		}
+	}()
+
+	handler.ServeHTTP(w, req)
+}
@@ -0,0 +1,23 @@
+package idempotency
+
+import "time"
+
+// Record is a stored Idempotency-Key together with the response of the request that first used it.
+// StatusCode is zero while that request is still being processed, which holds the key until
+// LockedUntil.
+type Record struct {
+	Scope        string
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	LockedUntil  time.Time
+}
+
+// Completed reports whether the original response has been stored.
+func (r Record) Completed() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,125 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	Reserve(ctx context.Context, record *Record) (Record, bool, error)
+	Complete(ctx context.Context, scope string, key string, statusCode int, contentType string, body []byte) error
+	Release(ctx context.Context, scope string, key string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type pgxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &pgxRepository{db: db}
+}
+
+// maxReserveAttempts bounds the retries of a reservation whose conflicting key is released
+// before it can be read.
+const maxReserveAttempts = 3
+
+// Reserve stores the record as in progress. When an unexpired record already exists for the key
+// it is returned instead, with false, unless it is in progress past its lease and is taken over.
+func (r *pgxRepository) Reserve(ctx context.Context, rec *Record) (Record, bool, error) {
+	for attempt := 1; ; attempt++ {
+		existing, reserved, err := r.reserve(ctx, rec)
+		if errors.Is(err, pgx.ErrNoRows) && attempt < maxReserveAttempts {
+			continue
+		}
+		return existing, reserved, err
+	}
+}
+
+// reserve makes one attempt at Reserve. It fails wrapping pgx.ErrNoRows when the conflicting key is
+// released between the insert and the read.
+func (r *pgxRepository) reserve(ctx context.Context, rec *Record) (Record, bool, error) {
+	query := `
+		INSERT INTO idempotency_key (scope, key, request_hash, created_at, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (scope, key) DO UPDATE
+			SET request_hash  = EXCLUDED.request_hash,
+				status_code   = NULL,
+				content_type  = NULL,
+				response_body = NULL,
+				created_at    = EXCLUDED.created_at,
+				expires_at    = EXCLUDED.expires_at,
+				locked_until  = EXCLUDED.locked_until
+			WHERE idempotency_key.expires_at <= EXCLUDED.created_at
+				OR (idempotency_key.status_code IS NULL AND idempotency_key.locked_until <= EXCLUDED.created_at)
+		RETURNING created_at
+	`
+
+	row := r.db.QueryRow(ctx, query, rec.Scope, rec.Key, rec.RequestHash, rec.CreatedAt, rec.ExpiresAt, rec.LockedUntil)
+	err := row.Scan(&rec.CreatedAt)
+	if err == nil {
+		return *rec, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Record{}, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	query = `
+		SELECT scope, key, request_hash, COALESCE(status_code, 0), COALESCE(content_type, ''), response_body, created_at, expires_at,
+			COALESCE(locked_until, expires_at)
+		FROM idempotency_key WHERE scope=$1 AND key=$2
+	`
+
+	var existing Record
+	err = r.db.QueryRow(ctx, query, rec.Scope, rec.Key).Scan(
+		&existing.Scope,
+		&existing.Key,
+		&existing.RequestHash,
+		&existing.StatusCode,
+		&existing.ContentType,
+		&existing.ResponseBody,
+		&existing.CreatedAt,
+		&existing.ExpiresAt,
+		&existing.LockedUntil,
+	)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	return existing, false, nil
+}
+
+func (r *pgxRepository) Complete(ctx context.Context, scope string, key string, statusCode int, contentType string, body []byte) error {
+	query := `
+		UPDATE idempotency_key SET status_code=$1, content_type=$2, response_body=$3
+		WHERE scope=$4 AND key=$5
+	`
+	_, err := r.db.Exec(ctx, query, statusCode, contentType, body, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *pgxRepository) Release(ctx context.Context, scope string, key string) error {
+	query := `DELETE FROM idempotency_key WHERE scope=$1 AND key=$2 AND status_code IS NULL`
+	_, err := r.db.Exec(ctx, query, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *pgxRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	query := `DELETE FROM idempotency_key WHERE expires_at <= $1`
+	tag, err := r.db.Exec(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,35 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by the repository when the account does not exist.
+var ErrNotFound = errors.New("account not found")
+
+// ErrDuplicateDocument is returned by the repository when another account has the same document number.
+var ErrDuplicateDocument = errors.New("an account with this document number already exists")
+
+// ErrInsufficientLimit is returned by the repository when a reservation exceeds the available limit.
+var ErrInsufficientLimit = errors.New("insufficient available limit")
+
+// ErrBlocked is returned when a debit is made on a blocked account.
+var ErrBlocked = errors.New("account is blocked")
+
+// ErrClosed is returned when a transaction other than a debt settlement, or a credit exceeding the
+// debt due, is made on a closed account.
+var ErrClosed = errors.New("account is closed")
+
+// ErrInvalidStatusTransition is returned when the account cannot change to the requested status.
+var ErrInvalidStatusTransition = errors.New("invalid account status transition")
+
+// ValidationError reports an account input that is not acceptable.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
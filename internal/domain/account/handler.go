@@ -1,24 +1,38 @@
 package account
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
+	"github.com/rikw22/challenge-money/internal/common/database"
 	"github.com/rikw22/challenge-money/pkg/httperrors"
+	"github.com/rikw22/challenge-money/pkg/money"
+	"github.com/rikw22/challenge-money/pkg/validators"
 )
 
 type Handler struct {
 	validate   *validator.Validate
+	unitOfWork database.UnitOfWork
 	repository Repository
 }
 
-func NewHandler(validate *validator.Validate, repository Repository) *Handler {
+func NewHandler(validate *validator.Validate, unitOfWork database.UnitOfWork, repository Repository) *Handler {
+	validate.RegisterValidation("document", validators.Document)
+	validate.RegisterValidation("currency", validators.Currency)
+	validate.RegisterCustomTypeFunc(money.ValidationValue, money.Decimal{})
 	return &Handler{
 		validate:   validate,
+		unitOfWork: unitOfWork,
 		repository: repository,
 	}
 }
@@ -30,17 +44,20 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account, err := h.repository.GetByID(r.Context(), accountId)
+	id, err := parseID(accountId)
 	if err != nil {
-		render.Render(w, r, httperrors.ErrInternalServer(err))
+		renderError(w, r, err)
 		return
 	}
 
-	render.JSON(w, r, &GetResponse{
-		ID:             account.ID,
-		DocumentNumber: account.DocumentNumber,
-		CreatedAt:      account.CreatedAt.Format(time.RFC3339),
-	})
+	account, err := h.repository.GetByID(r.Context(), id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	response := newGetResponse(account)
+	render.JSON(w, r, &response)
 }
 
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
@@ -56,9 +73,34 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var account Account
-	account.DocumentNumber = input.DocumentNumber
+	account.DocumentNumber = validators.NormalizeDocument(input.DocumentNumber)
+	account.DocumentType = validators.DocumentType(account.DocumentNumber)
+	account.Currency = money.DefaultCurrency
+	if input.Currency != "" {
+		account.Currency = money.Currency(input.Currency)
+	}
 
-	err := h.repository.Create(r.Context(), &account)
+	creditLimit, err := input.CreditLimit.MinorUnits(account.Currency)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+	account.CreditLimit = int(creditLimit)
+	account.AllocationStrategy = input.AllocationStrategy
+
+	err = h.repository.Create(r.Context(), &account)
+	if errors.Is(err, ErrDuplicateDocument) {
+		// The unique constraint decides, so concurrent requests cannot both create the account
+		existing, err := h.repository.GetByDocumentNumber(r.Context(), account.DocumentNumber)
+		if err != nil {
+			renderError(w, r, err)
+			return
+		}
+		render.Render(w, r, httperrors.ErrConflict(ErrDuplicateDocument).
+			WithCode(httperrors.CodeDuplicateDocument).
+			WithMeta("account_id", existing.ID))
+		return
+	}
 	if err != nil {
 		render.Render(w, r, httperrors.ErrInternalServer(err))
 		return
@@ -66,7 +108,222 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, &CreateResponse{
-		ID:             account.ID,
-		DocumentNumber: account.DocumentNumber,
+		ID:                 account.ID,
+		DocumentNumber:     account.DocumentNumber,
+		DocumentType:       account.DocumentType,
+		Currency:           string(account.Currency),
+		CreditLimit:        money.ToDecimal(money.Amount(account.CreditLimit), account.Currency),
+		AvailableLimit:     money.ToDecimal(money.Amount(account.AvailableLimit), account.Currency),
+		Status:             string(account.Status),
+		AllocationStrategy: account.AllocationStrategy,
 	})
 }
+
+// UpdateStatus blocks, unblocks or closes an account, recording the reason in its status history.
+func (h *Handler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(chi.URLParam(r, "accountId"))
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	var input UpdateStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.validate.Struct(&input); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	var account Account
+	err = h.unitOfWork.Do(r.Context(), func(ctx context.Context) error {
+		// The lock keeps transactions of the account from running with the previous status
+		var err error
+		account, err = h.repository.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		next := Status(input.Status)
+		if !account.Status.CanChangeTo(next) {
+			return fmt.Errorf("%w from %s to %s", ErrInvalidStatusTransition, account.Status, next)
+		}
+
+		if err := h.repository.UpdateStatus(ctx, id, next); err != nil {
+			return err
+		}
+
+		change := StatusChange{AccountId: id, From: account.Status, To: next, Reason: input.Reason}
+		if err := h.repository.CreateStatusChange(ctx, &change); err != nil {
+			return err
+		}
+
+		account.Status = next
+		return nil
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	response := newGetResponse(account)
+	render.JSON(w, r, &response)
+}
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+// List searches accounts, a page at a time. Use next_cursor as the cursor parameter to fetch the next page.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseListFilter(r)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+
+	accounts, err := h.repository.List(r.Context(), filter)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	response := ListResponse{Data: make([]GetResponse, 0, pageSize)}
+	if len(accounts) > pageSize {
+		accounts = accounts[:pageSize]
+		last := accounts[pageSize-1]
+		response.NextCursor = encodeCursor(Cursor{ID: last.ID, CreatedAt: last.CreatedAt})
+	}
+
+	for _, account := range accounts {
+		response.Data = append(response.Data, newGetResponse(account))
+	}
+
+	render.JSON(w, r, &response)
+}
+
+func parseListFilter(r *http.Request) (ListFilter, error) {
+	query := r.URL.Query()
+	filter := ListFilter{Limit: defaultListLimit, Sort: ListSort{Field: SortByID}}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return ListFilter{}, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := query.Get("sort"); v != "" {
+		field, descending := strings.CutPrefix(v, "-")
+		if field != SortByID && field != SortByCreatedAt {
+			return ListFilter{}, fmt.Errorf("sort must be %s or %s, prefixed with - for descending order", SortByID, SortByCreatedAt)
+		}
+		filter.Sort = ListSort{Field: field, Descending: descending}
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			return ListFilter{}, errors.New("cursor is invalid")
+		}
+		filter.Cursor = &cursor
+	}
+
+	if v := query.Get("document_number"); v != "" {
+		filter.DocumentNumber = validators.NormalizeDocument(v)
+	}
+
+	if v := query.Get("status"); v != "" {
+		status := Status(v)
+		if status != StatusActive && status != StatusBlocked && status != StatusClosed {
+			return ListFilter{}, errors.New("status must be active, blocked or closed")
+		}
+		filter.Status = status
+	}
+
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(param); v != "" {
+			date, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return ListFilter{}, fmt.Errorf("%s must be a RFC 3339 date", param)
+			}
+			*target = &date
+		}
+	}
+
+	return filter, nil
+}
+
+// encodeCursor makes an opaque cursor of the creation date, in microseconds as stored by
+// the database, and the id of an account.
+func encodeCursor(c Cursor) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d.%d", c.CreatedAt.UnixMicro(), c.ID))
+}
+
+func decodeCursor(value string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, err
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return Cursor{}, errors.New("malformed cursor")
+	}
+	micros, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return Cursor{}, err
+	}
+	accountId, err := strconv.Atoi(id)
+	if err != nil {
+		return Cursor{}, err
+	}
+
+	return Cursor{ID: accountId, CreatedAt: time.UnixMicro(micros).UTC()}, nil
+}
+
+func newGetResponse(account Account) GetResponse {
+	return GetResponse{
+		ID:                 account.ID,
+		DocumentNumber:     account.DocumentNumber,
+		DocumentType:       account.DocumentType,
+		Currency:           string(account.Currency),
+		CreditLimit:        money.ToDecimal(money.Amount(account.CreditLimit), account.Currency),
+		AvailableLimit:     money.ToDecimal(money.Amount(account.AvailableLimit), account.Currency),
+		Status:             string(account.Status),
+		AllocationStrategy: account.AllocationStrategy,
+		CreatedAt:          account.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func parseID(value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, &ValidationError{Field: "account_id", Reason: "must be a positive integer"}
+	}
+	return id, nil
+}
+
+// renderError translates domain errors into HTTP error responses.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *ValidationError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeAccountNotFound))
+	case errors.Is(err, ErrInvalidStatusTransition):
+		render.Render(w, r, httperrors.ErrConflict(err).WithCode(httperrors.CodeInvalidStatusTransition))
+	case errors.As(err, &validationErr):
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+	default:
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
@@ -15,18 +16,66 @@ import (
 )
 
 type mockRepository struct {
-	createFunc func(ctx context.Context, account *Account) error
-	getFunc    func(ctx context.Context, accountId string) (Account, error)
-	existFunc  func(ctx context.Context, id int) (bool, error)
+	createFunc              func(ctx context.Context, account *Account) error
+	getFunc                 func(ctx context.Context, accountId int) (Account, error)
+	getByDocumentNumberFunc func(ctx context.Context, documentNumber string) (Account, error)
+	existFunc               func(ctx context.Context, id int) (bool, error)
+	getForUpdateFunc        func(ctx context.Context, id int) (Account, error)
+	updateStatusFunc        func(ctx context.Context, id int, status Status) error
+	createStatusChangeFunc  func(ctx context.Context, change *StatusChange) error
+	listFunc                func(ctx context.Context, filter ListFilter) ([]Account, error)
 }
 
-func (m *mockRepository) GetByID(ctx context.Context, accountId string) (Account, error) {
+func (m *mockRepository) List(ctx context.Context, filter ListFilter) ([]Account, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx, filter)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetByIDForUpdate(ctx context.Context, id int) (Account, error) {
+	if m.getForUpdateFunc != nil {
+		return m.getForUpdateFunc(ctx, id)
+	}
+	return Account{}, errors.New("not implemented")
+}
+
+func (m *mockRepository) UpdateStatus(ctx context.Context, id int, status Status) error {
+	if m.updateStatusFunc != nil {
+		return m.updateStatusFunc(ctx, id, status)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) CreateStatusChange(ctx context.Context, change *StatusChange) error {
+	if m.createStatusChangeFunc != nil {
+		return m.createStatusChangeFunc(ctx, change)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) ReserveLimit(ctx context.Context, id int, amount int) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) RestoreLimit(ctx context.Context, id int, amount int) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) GetByID(ctx context.Context, accountId int) (Account, error) {
 	if m.getFunc != nil {
 		return m.getFunc(ctx, accountId)
 	}
 	return Account{}, errors.New("not implemented")
 }
 
+func (m *mockRepository) GetByDocumentNumber(ctx context.Context, documentNumber string) (Account, error) {
+	if m.getByDocumentNumberFunc != nil {
+		return m.getByDocumentNumberFunc(ctx, documentNumber)
+	}
+	return Account{}, errors.New("not implemented")
+}
+
 func (m *mockRepository) Create(ctx context.Context, account *Account) error {
 	if m.createFunc != nil {
 		return m.createFunc(ctx, account)
@@ -41,6 +90,18 @@ func (m *mockRepository) Exist(ctx context.Context, id int) (bool, error) {
 	return false, errors.New("not implemented")
 }
 
+type mockUnitOfWork struct {
+	committed bool
+}
+
+func (m *mockUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	m.committed = true
+	return nil
+}
+
 func TestHandler_Get(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -52,10 +113,12 @@ func TestHandler_Get(t *testing.T) {
 			name:      "valid request",
 			accountId: "1",
 			setupMock: func(m *mockRepository) {
-				m.getFunc = func(ctx context.Context, accountId string) (Account, error) {
+				m.getFunc = func(ctx context.Context, accountId int) (Account, error) {
 					return Account{
 						ID:             1,
-						DocumentNumber: "12345678900",
+						DocumentNumber: "52998224725",
+						DocumentType:   "CPF",
+						Currency:       "BRL",
 						CreatedAt:      time.Now(),
 					}, nil
 				}
@@ -68,11 +131,33 @@ func TestHandler_Get(t *testing.T) {
 			setupMock:      nil,
 			expectedStatus: http.StatusNotFound,
 		},
+		{
+			name:           "non-numeric account id",
+			accountId:      "abc",
+			setupMock:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative account id",
+			accountId:      "-1",
+			setupMock:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:      "account not found",
+			accountId: "999",
+			setupMock: func(m *mockRepository) {
+				m.getFunc = func(ctx context.Context, accountId int) (Account, error) {
+					return Account{}, ErrNotFound
+				}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
 		{
 			name:      "repository error",
 			accountId: "1",
 			setupMock: func(m *mockRepository) {
-				m.getFunc = func(ctx context.Context, accountId string) (Account, error) {
+				m.getFunc = func(ctx context.Context, accountId int) (Account, error) {
 					return Account{}, errors.New("database error")
 				}
 			},
@@ -86,7 +171,7 @@ func TestHandler_Get(t *testing.T) {
 			if tt.setupMock != nil {
 				tt.setupMock(mockRepo)
 			}
-			handler := NewHandler(validator.New(), mockRepo)
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo)
 
 			req := httptest.NewRequest(http.MethodGet, "/accounts/"+tt.accountId, nil)
 			rctx := chi.NewRouteContext()
@@ -114,6 +199,14 @@ func TestHandler_Get(t *testing.T) {
 				if response.DocumentNumber == "" {
 					t.Error("expected non-empty document_number in response")
 				}
+
+				if response.DocumentType == "" {
+					t.Error("expected non-empty document_type in response")
+				}
+
+				if response.Currency == "" {
+					t.Error("expected non-empty currency in response")
+				}
 			}
 		})
 	}
@@ -129,7 +222,7 @@ func TestHandler_Create(t *testing.T) {
 		{
 			name: "valid request",
 			body: CreateRequest{
-				DocumentNumber: "12345678900",
+				DocumentNumber: "52998224725",
 			},
 			setupMock: func(m *mockRepository) {
 				m.createFunc = func(ctx context.Context, account *Account) error {
@@ -140,6 +233,135 @@ func TestHandler_Create(t *testing.T) {
 			},
 			expectedStatus: http.StatusCreated,
 		},
+		{
+			name: "valid formatted cnpj",
+			body: CreateRequest{
+				DocumentNumber: "11.222.333/0001-81",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, account *Account) error {
+					if account.DocumentNumber != "11222333000181" || account.DocumentType != "CNPJ" {
+						return errors.New("document not normalized")
+					}
+					account.ID = 1
+					account.CreatedAt = time.Now()
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "valid request with currency",
+			body: CreateRequest{
+				DocumentNumber: "52998224725",
+				Currency:       "USD",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, account *Account) error {
+					if account.Currency != "USD" {
+						return errors.New("currency not set")
+					}
+					account.ID = 1
+					account.CreatedAt = time.Now()
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "valid request with credit limit",
+			body: map[string]interface{}{
+				"document_number": "52998224725",
+				"credit_limit":    "1500.00",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, account *Account) error {
+					if account.CreditLimit != 150000 {
+						return errors.New("credit limit not set")
+					}
+					account.ID = 1
+					account.AvailableLimit = account.CreditLimit
+					account.CreatedAt = time.Now()
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "valid request with allocation strategy",
+			body: CreateRequest{
+				DocumentNumber:     "52998224725",
+				AllocationStrategy: "withdrawals_first",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, account *Account) error {
+					if account.AllocationStrategy != "withdrawals_first" {
+						return errors.New("allocation strategy not set")
+					}
+					account.ID = 1
+					account.CreatedAt = time.Now()
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "unknown allocation strategy",
+			body: CreateRequest{
+				DocumentNumber:     "52998224725",
+				AllocationStrategy: "newest_first",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "negative credit limit",
+			body: map[string]interface{}{
+				"document_number": "52998224725",
+				"credit_limit":    -100,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "credit limit with too many decimal places",
+			body: map[string]interface{}{
+				"document_number": "52998224725",
+				"credit_limit":    "100.001",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "credit limit above the largest amount",
+			body: map[string]interface{}{
+				"document_number": "52998224725",
+				"credit_limit":    "21474836.48",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "unsupported currency",
+			body: CreateRequest{
+				DocumentNumber: "52998224725",
+				Currency:       "XXX",
+			},
+			setupMock:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid cpf check digits",
+			body: CreateRequest{
+				DocumentNumber: "52998224726",
+			},
+			setupMock:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "document too long",
+			body: CreateRequest{
+				DocumentNumber: "112223330001810",
+			},
+			setupMock:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "empty body",
 			body:           map[string]interface{}{},
@@ -168,10 +390,25 @@ func TestHandler_Create(t *testing.T) {
 			setupMock:      nil,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name: "duplicate document number",
+			body: CreateRequest{
+				DocumentNumber: "529.982.247-25",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, account *Account) error {
+					return ErrDuplicateDocument
+				}
+				m.getByDocumentNumberFunc = func(ctx context.Context, documentNumber string) (Account, error) {
+					return Account{ID: 7, DocumentNumber: documentNumber}, nil
+				}
+			},
+			expectedStatus: http.StatusConflict,
+		},
 		{
 			name: "repository error",
 			body: CreateRequest{
-				DocumentNumber: "12345678900",
+				DocumentNumber: "52998224725",
 			},
 			setupMock: func(m *mockRepository) {
 				m.createFunc = func(ctx context.Context, account *Account) error {
@@ -188,7 +425,7 @@ func TestHandler_Create(t *testing.T) {
 			if tt.setupMock != nil {
 				tt.setupMock(mockRepo)
 			}
-			handler := NewHandler(validator.New(), mockRepo)
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo)
 
 			var bodyBytes []byte
 			var err error
@@ -212,6 +449,24 @@ func TestHandler_Create(t *testing.T) {
 				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
 			}
 
+			if tt.expectedStatus == http.StatusConflict {
+				var response struct {
+					Code string         `json:"code"`
+					Meta map[string]int `json:"meta"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				if response.Code != "DUPLICATE_DOCUMENT" {
+					t.Errorf("expected code DUPLICATE_DOCUMENT, got %s", response.Code)
+				}
+
+				if response.Meta["account_id"] != 7 {
+					t.Errorf("expected existing account_id 7 in response, got %v", response.Meta)
+				}
+			}
+
 			if tt.expectedStatus == http.StatusCreated {
 				var response GetResponse
 				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
@@ -225,6 +480,299 @@ func TestHandler_Create(t *testing.T) {
 				if response.DocumentNumber == "" {
 					t.Error("expected non-empty document_number in response")
 				}
+
+				if response.DocumentType == "" {
+					t.Error("expected non-empty document_type in response")
+				}
+
+				if response.Currency == "" {
+					t.Error("expected non-empty currency in response")
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	createdAt := time.Date(2025, 10, 27, 0, 18, 14, 123456000, time.UTC)
+	accounts := []Account{
+		{ID: 1, DocumentNumber: "52998224725", DocumentType: "CPF", Currency: "BRL", Status: StatusActive, CreatedAt: createdAt},
+		{ID: 2, DocumentNumber: "11222333000181", DocumentType: "CNPJ", Currency: "BRL", Status: StatusActive, CreatedAt: createdAt.Add(time.Second)},
+		{ID: 3, DocumentNumber: "39053344705", DocumentType: "CPF", Currency: "USD", Status: StatusBlocked, CreatedAt: createdAt.Add(2 * time.Second)},
+	}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		query              string
+		repositoryErr      error
+		expectedStatus     int
+		expectedFilter     ListFilter
+		expectedCount      int
+		expectedNextCursor bool
+	}{
+		{
+			name:           "default page",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedFilter: ListFilter{Sort: ListSort{Field: SortByID}, Limit: defaultListLimit + 1},
+			expectedCount:  3,
+		},
+		{
+			name:               "filters and sort",
+			query:              "?document_number=529.982.247-25&status=active&from=2025-01-01T00:00:00Z&sort=-created_at&limit=2",
+			expectedStatus:     http.StatusOK,
+			expectedFilter:     ListFilter{DocumentNumber: "52998224725", Status: StatusActive, From: &from, Sort: ListSort{Field: SortByCreatedAt, Descending: true}, Limit: 3},
+			expectedCount:      2,
+			expectedNextCursor: true,
+		},
+		{
+			name:           "cursor",
+			query:          "?cursor=" + encodeCursor(Cursor{ID: 2, CreatedAt: createdAt}),
+			expectedStatus: http.StatusOK,
+			expectedFilter: ListFilter{Sort: ListSort{Field: SortByID}, Cursor: &Cursor{ID: 2, CreatedAt: createdAt}, Limit: defaultListLimit + 1},
+			expectedCount:  3,
+		},
+		{
+			name:           "invalid cursor",
+			query:          "?cursor=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid sort",
+			query:          "?sort=document_number",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid status",
+			query:          "?status=frozen",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "limit too large",
+			query:          "?limit=101",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid from",
+			query:          "?from=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "repository error",
+			query:          "",
+			repositoryErr:  errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter ListFilter
+			mockRepo := &mockRepository{
+				listFunc: func(ctx context.Context, f ListFilter) ([]Account, error) {
+					filter = f
+					if tt.repositoryErr != nil {
+						return nil, tt.repositoryErr
+					}
+					return accounts[:min(len(accounts), f.Limit)], nil
+				},
+			}
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, "/accounts"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.List(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if !reflect.DeepEqual(filter, tt.expectedFilter) {
+				t.Errorf("expected filter %+v, got %+v", tt.expectedFilter, filter)
+			}
+
+			var response ListResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if len(response.Data) != tt.expectedCount {
+				t.Errorf("expected %d accounts, got %d", tt.expectedCount, len(response.Data))
+			}
+
+			if !tt.expectedNextCursor {
+				if response.NextCursor != "" {
+					t.Errorf("expected no next cursor, got %s", response.NextCursor)
+				}
+				return
+			}
+
+			cursor, err := decodeCursor(response.NextCursor)
+			if err != nil {
+				t.Fatalf("failed to decode next cursor: %v", err)
+			}
+			last := accounts[tt.expectedCount-1]
+			if cursor.ID != last.ID || !cursor.CreatedAt.Equal(last.CreatedAt) {
+				t.Errorf("expected cursor of account %d, got %+v", last.ID, cursor)
+			}
+		})
+	}
+}
+
+func TestHandler_UpdateStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		accountId      string
+		currentStatus  Status
+		body           interface{}
+		getErr         error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "block an active account",
+			accountId:      "1",
+			currentStatus:  StatusActive,
+			body:           UpdateStatusRequest{Status: "blocked", Reason: "suspected fraud"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unblock a blocked account",
+			accountId:      "1",
+			currentStatus:  StatusBlocked,
+			body:           UpdateStatusRequest{Status: "active", Reason: "fraud ruled out"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "close a blocked account",
+			accountId:      "1",
+			currentStatus:  StatusBlocked,
+			body:           UpdateStatusRequest{Status: "closed", Reason: "customer request"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "reopen a closed account",
+			accountId:      "1",
+			currentStatus:  StatusClosed,
+			body:           UpdateStatusRequest{Status: "active", Reason: "customer request"},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "INVALID_STATUS_TRANSITION",
+		},
+		{
+			name:           "same status",
+			accountId:      "1",
+			currentStatus:  StatusActive,
+			body:           UpdateStatusRequest{Status: "active", Reason: "no change"},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "INVALID_STATUS_TRANSITION",
+		},
+		{
+			name:           "unknown status",
+			accountId:      "1",
+			body:           UpdateStatusRequest{Status: "frozen", Reason: "suspected fraud"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing reason",
+			accountId:      "1",
+			body:           UpdateStatusRequest{Status: "blocked"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid account id",
+			accountId:      "abc",
+			body:           UpdateStatusRequest{Status: "blocked", Reason: "suspected fraud"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "account not found",
+			accountId:      "999",
+			body:           UpdateStatusRequest{Status: "blocked", Reason: "suspected fraud"},
+			getErr:         ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "ACCOUNT_NOT_FOUND",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var change *StatusChange
+			var updatedStatus Status
+
+			mockRepo := &mockRepository{
+				getForUpdateFunc: func(ctx context.Context, id int) (Account, error) {
+					if tt.getErr != nil {
+						return Account{}, tt.getErr
+					}
+					return Account{ID: id, DocumentNumber: "52998224725", DocumentType: "CPF", Currency: "BRL", Status: tt.currentStatus, CreatedAt: time.Now()}, nil
+				},
+				updateStatusFunc: func(ctx context.Context, id int, status Status) error {
+					updatedStatus = status
+					return nil
+				},
+				createStatusChangeFunc: func(ctx context.Context, c *StatusChange) error {
+					change = c
+					return nil
+				},
+			}
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo)
+
+			bodyBytes, err := json.Marshal(tt.body)
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/accounts/"+tt.accountId+"/status", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("accountId", tt.accountId)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.UpdateStatus(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				if tt.expectedCode != "" {
+					var response struct {
+						Code string `json:"code"`
+					}
+					if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+						t.Fatalf("failed to decode response: %v", err)
+					}
+					if response.Code != tt.expectedCode {
+						t.Errorf("expected code %s, got %s", tt.expectedCode, response.Code)
+					}
+				}
+				if change != nil {
+					t.Error("expected no status change to be recorded")
+				}
+				return
+			}
+
+			request := tt.body.(UpdateStatusRequest)
+			if updatedStatus != Status(request.Status) {
+				t.Errorf("expected status updated to %s, got %s", request.Status, updatedStatus)
+			}
+			if change == nil || change.From != tt.currentStatus || change.To != Status(request.Status) || change.Reason != request.Reason {
+				t.Errorf("expected status change from %s to %s recorded, got %+v", tt.currentStatus, request.Status, change)
+			}
+
+			var response GetResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.Status != request.Status {
+				t.Errorf("expected status %s in response, got %s", request.Status, response.Status)
 			}
 		})
 	}
@@ -1,24 +1,139 @@
 package account
 
-import "time"
+import (
+	"time"
+
+	"github.com/rikw22/challenge-money/pkg/money"
+)
 
 type CreateRequest struct {
-	DocumentNumber string `json:"document_number" validate:"required"`
+	DocumentNumber string        `json:"document_number" validate:"required,document"`
+	Currency       string        `json:"currency" validate:"omitempty,currency"`
+	CreditLimit    money.Decimal `json:"credit_limit" validate:"gte=0"` // in the account currency, defaults to 0
+
+	// AllocationStrategy splits the payments among the debts of the account, defaults to oldest_first
+	AllocationStrategy string `json:"allocation_strategy" validate:"omitempty,oneof=oldest_first withdrawals_first highest_interest_first proportional"`
+}
+
+type UpdateStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=active blocked closed"`
+	Reason string `json:"reason" validate:"required,max=255"`
 }
 
 type CreateResponse struct {
-	ID             int    `json:"account_id"`
-	DocumentNumber string `json:"document_number"`
+	ID                 int           `json:"account_id"`
+	DocumentNumber     string        `json:"document_number"`
+	DocumentType       string        `json:"document_type"`
+	Currency           string        `json:"currency"`
+	CreditLimit        money.Decimal `json:"credit_limit"`
+	AvailableLimit     money.Decimal `json:"available_limit"`
+	Status             string        `json:"status"`
+	AllocationStrategy string        `json:"allocation_strategy,omitempty"`
 }
 
 type GetResponse struct {
-	ID             int    `json:"account_id"`
-	DocumentNumber string `json:"document_number"`
-	CreatedAt      string `json:"created_at"`
+	ID                 int           `json:"account_id"`
+	DocumentNumber     string        `json:"document_number"`
+	DocumentType       string        `json:"document_type"`
+	Currency           string        `json:"currency"`
+	CreditLimit        money.Decimal `json:"credit_limit"`
+	AvailableLimit     money.Decimal `json:"available_limit"`
+	Status             string        `json:"status"`
+	AllocationStrategy string        `json:"allocation_strategy,omitempty"`
+	CreatedAt          string        `json:"created_at"`
+}
+
+type ListResponse struct {
+	Data       []GetResponse `json:"data"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// ListFilter selects a page of accounts. Pointer and zero fields are not applied.
+type ListFilter struct {
+	DocumentNumber string
+	Status         Status
+	From           *time.Time
+	To             *time.Time
+	Sort           ListSort
+	Cursor         *Cursor
+	Limit          int
+}
+
+// ListSort orders accounts by SortByID or SortByCreatedAt. Ties on the creation date are
+// broken by id, so pages never overlap.
+type ListSort struct {
+	Field      string
+	Descending bool
+}
+
+const (
+	SortByID        = "id"
+	SortByCreatedAt = "created_at"
+)
+
+// Cursor is the sort key of the last account of the previous page.
+type Cursor struct {
+	ID        int
+	CreatedAt time.Time
 }
 
 type Account struct {
 	ID             int
-	DocumentNumber string
+	DocumentNumber string // digits only, see validators.NormalizeDocument
+	DocumentType   string // validators.DocumentTypeCPF or validators.DocumentTypeCNPJ
+	Currency       money.Currency
+	Status         Status
 	CreatedAt      time.Time
+
+	// CreditLimit is the most the account may owe, in minor units of its currency, and
+	// AvailableLimit what is left of it. Debts in other currencies do not use the limit.
+	CreditLimit    int
+	AvailableLimit int
+
+	// AllocationStrategy names how payments are split among the debts of the account, see
+	// transaction.AllocationStrategy. Empty means the default, oldest first.
+	AllocationStrategy string
+}
+
+// Status is the lifecycle state of an account.
+type Status string
+
+const (
+	StatusActive  Status = "active"
+	StatusBlocked Status = "blocked"
+	StatusClosed  Status = "closed"
+)
+
+// CanChangeTo reports whether an account may move from s to next. Blocked accounts can be
+// unblocked, and any account that is not closed can be closed, which is final.
+func (s Status) CanChangeTo(next Status) bool {
+	switch s {
+	case StatusActive:
+		return next == StatusBlocked || next == StatusClosed
+	case StatusBlocked:
+		return next == StatusActive || next == StatusClosed
+	default:
+		return false
+	}
+}
+
+// CheckTransaction returns ErrBlocked or ErrClosed when the account cannot receive a transaction.
+// Blocked accounts only refuse debits, while closed accounts only take credits settling debt.
+func (a Account) CheckTransaction(isDebit bool, dischargesBalance bool) error {
+	switch {
+	case a.Status == StatusBlocked && isDebit:
+		return ErrBlocked
+	case a.Status == StatusClosed && (isDebit || !dischargesBalance):
+		return ErrClosed
+	}
+	return nil
+}
+
+// StatusChange records a status change of an account and why it was made.
+type StatusChange struct {
+	AccountId int
+	From      Status
+	To        Status
+	Reason    string
+	ChangedAt time.Time
 }
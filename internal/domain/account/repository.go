@@ -2,17 +2,34 @@ package account
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rikw22/challenge-money/internal/common/database"
 )
 
 type Repository interface {
-	GetByID(ctx context.Context, id string) (Account, error)
+	GetByID(ctx context.Context, id int) (Account, error)
+	GetByDocumentNumber(ctx context.Context, documentNumber string) (Account, error)
 	Create(ctx context.Context, account *Account) error
+	GetByIDForUpdate(ctx context.Context, id int) (Account, error)
 	Exist(ctx context.Context, id int) (bool, error)
+	List(ctx context.Context, filter ListFilter) ([]Account, error)
+	UpdateStatus(ctx context.Context, id int, status Status) error
+	CreateStatusChange(ctx context.Context, change *StatusChange) error
+	ReserveLimit(ctx context.Context, id int, amount int) error
+	RestoreLimit(ctx context.Context, id int, amount int) error
 }
 
+const (
+	uniqueViolationCode          = "23505"
+	documentNumberConstraintName = "account_document_number_key"
+)
+
 type pgxRepository struct {
 	db *pgxpool.Pool
 }
@@ -21,19 +38,134 @@ func NewRepository(db *pgxpool.Pool) Repository {
 	return &pgxRepository{db: db}
 }
 
-func (r *pgxRepository) GetByID(ctx context.Context, id string) (Account, error) {
-	query := `SELECT id, document_number, created_at FROM account WHERE id = $1`
+// accountColumns are the columns read by scanAccount.
+const accountColumns = `id, document_number, document_type, currency, credit_limit, available_limit, status,
+	COALESCE(allocation_strategy, ''), created_at`
+
+func (r *pgxRepository) GetByID(ctx context.Context, id int) (Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM account WHERE id = $1`
+
+	a, err := scanAccount(database.Conn(ctx, r.db).QueryRow(ctx, query, id))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return Account{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return a, err
+}
+
+// GetByIDForUpdate reads the account and locks it until the end of the unit of work in ctx.
+func (r *pgxRepository) GetByIDForUpdate(ctx context.Context, id int) (Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM account WHERE id = $1 FOR UPDATE`
 
-	row := r.db.QueryRow(ctx, query, id)
+	a, err := scanAccount(database.Conn(ctx, r.db).QueryRow(ctx, query, id))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return Account{}, fmt.Errorf("failed to lock account: %w", err)
+	}
 
+	return a, err
+}
+
+func (r *pgxRepository) GetByDocumentNumber(ctx context.Context, documentNumber string) (Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM account WHERE document_number = $1`
+
+	a, err := scanAccount(database.Conn(ctx, r.db).QueryRow(ctx, query, documentNumber))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return Account{}, fmt.Errorf("failed to get user by document number: %w", err)
+	}
+
+	return a, err
+}
+
+// List returns a page of accounts in the order of filter.Sort, starting after filter.Cursor when set.
+func (r *pgxRepository) List(ctx context.Context, filter ListFilter) ([]Account, error) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+
+	addCondition := func(condition string, values ...any) {
+		placeholders := make([]any, 0, len(values))
+		for _, value := range values {
+			args = append(args, value)
+			placeholders = append(placeholders, len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if filter.DocumentNumber != "" {
+		addCondition("document_number=$%d", filter.DocumentNumber)
+	}
+	if filter.Status != "" {
+		addCondition("status=$%d", filter.Status)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Sort.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	orderBy := "id " + direction
+	if filter.Sort.Field == SortByCreatedAt {
+		orderBy = "created_at " + direction + ", id " + direction
+	}
+	if filter.Cursor != nil {
+		if filter.Sort.Field == SortByCreatedAt {
+			addCondition("(created_at, id) "+comparison+" ($%d, $%d)", filter.Cursor.CreatedAt, filter.Cursor.ID)
+		} else {
+			addCondition("id "+comparison+" $%d", filter.Cursor.ID)
+		}
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT `+accountColumns+`
+		FROM account WHERE %s
+		ORDER BY %s
+		LIMIT $%d
+		`, strings.Join(conditions, " AND "), orderBy, len(args))
+
+	rows, err := database.Conn(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+func scanAccount(row pgx.Row) (Account, error) {
 	var a Account
 	err := row.Scan(
 		&a.ID,
 		&a.DocumentNumber,
+		&a.DocumentType,
+		&a.Currency,
+		&a.CreditLimit,
+		&a.AvailableLimit,
+		&a.Status,
+		&a.AllocationStrategy,
 		&a.CreatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Account{}, ErrNotFound
+	}
 	if err != nil {
-		return Account{}, fmt.Errorf("failed to get user: %w", err)
+		return Account{}, err
 	}
 
 	return a, nil
@@ -41,16 +173,23 @@ func (r *pgxRepository) GetByID(ctx context.Context, id string) (Account, error)
 
 func (r *pgxRepository) Create(ctx context.Context, a *Account) error {
 	query := `
-		INSERT INTO account (document_number) VALUES ($1)
-		RETURNING id, created_at
+		INSERT INTO account (document_number, document_type, currency, credit_limit, available_limit, allocation_strategy)
+		VALUES ($1, $2, $3, $4, $4, NULLIF($5, ''))
+		RETURNING id, available_limit, status, created_at
 	`
 
-	row := r.db.QueryRow(ctx, query, a.DocumentNumber)
+	row := database.Conn(ctx, r.db).QueryRow(ctx, query, a.DocumentNumber, a.DocumentType, a.Currency, a.CreditLimit, a.AllocationStrategy)
 
 	err := row.Scan(
 		&a.ID,
+		&a.AvailableLimit,
+		&a.Status,
 		&a.CreatedAt,
 	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == documentNumberConstraintName {
+		return ErrDuplicateDocument
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -62,7 +201,7 @@ func (r *pgxRepository) Exist(ctx context.Context, id int) (bool, error) {
 	query := `SELECT COUNT(id)>0 FROM account WHERE id=$1;`
 
 	exist := false
-	row := r.db.QueryRow(ctx, query, id)
+	row := database.Conn(ctx, r.db).QueryRow(ctx, query, id)
 	err := row.Scan(
 		&exist,
 	)
@@ -72,3 +211,59 @@ func (r *pgxRepository) Exist(ctx context.Context, id int) (bool, error) {
 
 	return exist, nil
 }
+
+// ReserveLimit takes amount from the available limit of the account. The check and the
+// update are a single statement, so concurrent reservations cannot overdraw the limit.
+func (r *pgxRepository) ReserveLimit(ctx context.Context, id int, amount int) error {
+	query := `UPDATE account SET available_limit = available_limit - $2 WHERE id=$1 AND available_limit >= $2`
+
+	tag, err := database.Conn(ctx, r.db).Exec(ctx, query, id, amount)
+	if err != nil {
+		return fmt.Errorf("failed to reserve limit: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInsufficientLimit
+	}
+
+	return nil
+}
+
+// RestoreLimit gives amount back to the available limit of the account, never above its credit limit.
+func (r *pgxRepository) RestoreLimit(ctx context.Context, id int, amount int) error {
+	query := `UPDATE account SET available_limit = LEAST(credit_limit, available_limit + $2) WHERE id=$1`
+
+	_, err := database.Conn(ctx, r.db).Exec(ctx, query, id, amount)
+	if err != nil {
+		return fmt.Errorf("failed to restore limit: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgxRepository) UpdateStatus(ctx context.Context, id int, status Status) error {
+	query := `UPDATE account SET status=$2 WHERE id=$1`
+
+	tag, err := database.Conn(ctx, r.db).Exec(ctx, query, id, status)
+	if err != nil {
+		return fmt.Errorf("failed to update account status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *pgxRepository) CreateStatusChange(ctx context.Context, c *StatusChange) error {
+	query := `
+		INSERT INTO account_status_history (account_id, from_status, to_status, reason) VALUES ($1, $2, $3, $4)
+		RETURNING changed_at
+	`
+
+	err := database.Conn(ctx, r.db).QueryRow(ctx, query, c.AccountId, c.From, c.To, c.Reason).Scan(&c.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record account status change: %w", err)
+	}
+
+	return nil
+}
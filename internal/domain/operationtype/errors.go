@@ -0,0 +1,6 @@
+package operationtype
+
+import "errors"
+
+// ErrNotFound is returned by the repository when the operation type does not exist.
+var ErrNotFound = errors.New("operation type not found")
@@ -0,0 +1,157 @@
+package operationtype
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/rikw22/challenge-money/pkg/httperrors"
+)
+
+type Handler struct {
+	validate   *validator.Validate
+	repository Repository
+}
+
+func NewHandler(validate *validator.Validate, repository Repository) *Handler {
+	return &Handler{
+		validate:   validate,
+		repository: repository,
+	}
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	operationTypes, err := h.repository.List(r.Context())
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	response := ListResponse{Data: make([]Response, 0, len(operationTypes))}
+	for _, o := range operationTypes {
+		response.Data = append(response.Data, newResponse(o))
+	}
+
+	render.JSON(w, r, &response)
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	operationType, err := h.repository.GetByID(r.Context(), id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	render.JSON(w, r, newResponse(operationType))
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	var input CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.validate.Struct(&input); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	operationType := OperationType{
+		Description:        input.Description,
+		Direction:          Direction(input.Direction),
+		DischargesBalance:  input.DischargesBalance,
+		AllowsInstallments: input.AllowsInstallments,
+		InterestRate:       input.InterestRate,
+		Kind:               Kind(input.Kind),
+		AllocationStrategy: input.AllocationStrategy,
+	}
+	if operationType.IsDebit() && operationType.DischargesBalance {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("only credit operation types can discharge balances")))
+		return
+	}
+	if !operationType.IsDebit() && operationType.AllowsInstallments {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("only debit operation types can allow installments")))
+		return
+	}
+	if (operationType.Kind == KindPurchase || operationType.Kind == KindWithdrawal) && !operationType.IsDebit() {
+		render.Render(w, r, httperrors.ErrInvalidRequest(fmt.Errorf("%s operation types must be debits", operationType.Kind)))
+		return
+	}
+	if operationType.Kind == KindPayment && operationType.IsDebit() {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("payment operation types must be credits")))
+		return
+	}
+	if !operationType.DischargesBalance && operationType.AllocationStrategy != "" {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("only operation types discharging balances can have an allocation strategy")))
+		return
+	}
+
+	if err := h.repository.Create(r.Context(), &operationType); err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, newResponse(operationType))
+}
+
+// Deactivate keeps the operation type for the transactions that use it, but rejects new ones.
+func (h *Handler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	operationType, err := h.repository.Deactivate(r.Context(), id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	render.JSON(w, r, newResponse(operationType))
+}
+
+func parseID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		return 0, errors.New("operation type id must be a positive integer")
+	}
+	return id, nil
+}
+
+func newResponse(o OperationType) Response {
+	return Response{
+		ID:                 o.ID,
+		Description:        o.Description,
+		Direction:          string(o.Direction),
+		DischargesBalance:  o.DischargesBalance,
+		AllowsInstallments: o.AllowsInstallments,
+		InterestRate:       o.InterestRate,
+		Kind:               string(o.Kind),
+		AllocationStrategy: o.AllocationStrategy,
+		Active:             o.Active,
+	}
+}
+
+// renderError translates domain errors into HTTP error responses.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeOperationTypeUnknown))
+	default:
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+	}
+}
@@ -0,0 +1,488 @@
+package operationtype
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type mockRepository struct {
+	listFunc       func(ctx context.Context) ([]OperationType, error)
+	getByIDFunc    func(ctx context.Context, id int) (OperationType, error)
+	getByKindFunc  func(ctx context.Context, kind Kind, direction Direction) (OperationType, error)
+	existFunc      func(ctx context.Context, id int) (bool, error)
+	createFunc     func(ctx context.Context, operationType *OperationType) error
+	deactivateFunc func(ctx context.Context, id int) (OperationType, error)
+}
+
+func (m *mockRepository) List(ctx context.Context) ([]OperationType, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetByID(ctx context.Context, id int) (OperationType, error) {
+	if m.getByIDFunc != nil {
+		return m.getByIDFunc(ctx, id)
+	}
+	return OperationType{}, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetByKind(ctx context.Context, kind Kind, direction Direction) (OperationType, error) {
+	if m.getByKindFunc != nil {
+		return m.getByKindFunc(ctx, kind, direction)
+	}
+	return OperationType{}, errors.New("not implemented")
+}
+
+func (m *mockRepository) Exist(ctx context.Context, id int) (bool, error) {
+	if m.existFunc != nil {
+		return m.existFunc(ctx, id)
+	}
+	return false, errors.New("not implemented")
+}
+
+func (m *mockRepository) Create(ctx context.Context, operationType *OperationType) error {
+	if m.createFunc != nil {
+		return m.createFunc(ctx, operationType)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) Deactivate(ctx context.Context, id int) (OperationType, error) {
+	if m.deactivateFunc != nil {
+		return m.deactivateFunc(ctx, id)
+	}
+	return OperationType{}, errors.New("not implemented")
+}
+
+func TestHandler_List(t *testing.T) {
+	tests := []struct {
+		name           string
+		setupMock      func(*mockRepository)
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name: "valid request",
+			setupMock: func(m *mockRepository) {
+				m.listFunc = func(ctx context.Context) ([]OperationType, error) {
+					return []OperationType{
+						{ID: 1, Description: "Normal Purchase", Direction: DirectionDebit, Active: true},
+						{ID: 4, Description: "Credit Voucher", Direction: DirectionCredit, DischargesBalance: true, Active: true},
+					}, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name: "repository error",
+			setupMock: func(m *mockRepository) {
+				m.listFunc = func(ctx context.Context) ([]OperationType, error) {
+					return nil, errors.New("database error")
+				}
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{}
+			tt.setupMock(mockRepo)
+			handler := NewHandler(validator.New(), mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, "/operation-types", nil)
+			w := httptest.NewRecorder()
+
+			handler.List(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response ListResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if len(response.Data) != tt.expectedCount {
+				t.Errorf("expected %d operation types, got %d", tt.expectedCount, len(response.Data))
+			}
+		})
+	}
+}
+
+func TestHandler_Get(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		setupMock      func(*mockRepository)
+		expectedStatus int
+	}{
+		{
+			name: "valid request",
+			id:   "4",
+			setupMock: func(m *mockRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (OperationType, error) {
+					return OperationType{ID: id, Description: "Credit Voucher", Direction: DirectionCredit, DischargesBalance: true, Active: true}, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid id",
+			id:             "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "operation type does not exist",
+			id:   "99",
+			setupMock: func(m *mockRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (OperationType, error) {
+					return OperationType{}, ErrNotFound
+				}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "repository error",
+			id:   "4",
+			setupMock: func(m *mockRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (OperationType, error) {
+					return OperationType{}, errors.New("database error")
+				}
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{}
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+			handler := NewHandler(validator.New(), mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, "/operation-types/"+tt.id, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.Get(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response Response
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				expected := Response{ID: 4, Description: "Credit Voucher", Direction: "credit", DischargesBalance: true, Active: true}
+				if response != expected {
+					t.Errorf("expected response %+v, got %+v", expected, response)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Create(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           interface{}
+		setupMock      func(*mockRepository)
+		expectedStatus int
+	}{
+		{
+			name: "valid request",
+			body: CreateRequest{
+				Description:       "Bill Payment",
+				Direction:         "credit",
+				DischargesBalance: true,
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, operationType *OperationType) error {
+					operationType.ID = 5
+					operationType.Active = true
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "missing description",
+			body: CreateRequest{
+				Direction: "debit",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid direction",
+			body: CreateRequest{
+				Description: "Bill Payment",
+				Direction:   "sideways",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "discharging debit",
+			body: CreateRequest{
+				Description:       "Fee",
+				Direction:         "debit",
+				DischargesBalance: true,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "credit with installments",
+			body: CreateRequest{
+				Description:        "Refund",
+				Direction:          "credit",
+				AllowsInstallments: true,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "discharging credit with an allocation strategy",
+			body: CreateRequest{
+				Description:        "Bill Payment",
+				Direction:          "credit",
+				DischargesBalance:  true,
+				AllocationStrategy: "proportional",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, operationType *OperationType) error {
+					if operationType.AllocationStrategy != "proportional" {
+						t.Errorf("expected the proportional strategy, got %q", operationType.AllocationStrategy)
+					}
+					operationType.ID = 6
+					operationType.Active = true
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "unknown allocation strategy",
+			body: CreateRequest{
+				Description:        "Bill Payment",
+				Direction:          "credit",
+				DischargesBalance:  true,
+				AllocationStrategy: "newest_first",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "allocation strategy on a type not discharging balances",
+			body: CreateRequest{
+				Description:        "Cashback",
+				Direction:          "credit",
+				AllocationStrategy: "proportional",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "transfer kind",
+			body: CreateRequest{
+				Description: "Pix Transfer",
+				Direction:   "debit",
+				Kind:        "transfer",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, operationType *OperationType) error {
+					if operationType.Kind != KindTransfer {
+						t.Errorf("expected the transfer kind, got %q", operationType.Kind)
+					}
+					operationType.ID = 7
+					operationType.Active = true
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "unknown kind",
+			body: CreateRequest{
+				Description: "Fee",
+				Direction:   "debit",
+				Kind:        "fee",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "withdrawal kind on a credit",
+			body: CreateRequest{
+				Description: "Cash Deposit",
+				Direction:   "credit",
+				Kind:        "withdrawal",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "payment kind on a debit",
+			body: CreateRequest{
+				Description: "Fee",
+				Direction:   "debit",
+				Kind:        "payment",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "negative interest rate",
+			body: CreateRequest{
+				Description:  "Cash Advance",
+				Direction:    "debit",
+				InterestRate: -100,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid json",
+			body:           "invalid json",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "repository error",
+			body: CreateRequest{
+				Description: "Fee",
+				Direction:   "debit",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, operationType *OperationType) error {
+					return errors.New("database error")
+				}
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{}
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+			handler := NewHandler(validator.New(), mockRepo)
+
+			var bodyBytes []byte
+			var err error
+
+			if strBody, ok := tt.body.(string); ok {
+				bodyBytes = []byte(strBody)
+			} else {
+				bodyBytes, err = json.Marshal(tt.body)
+				if err != nil {
+					t.Fatalf("failed to marshal request body: %v", err)
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/operation-types", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusCreated {
+				var response Response
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				if response.ID == 0 || !response.Active {
+					t.Errorf("expected an active operation type with an id, got %+v", response)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Deactivate(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		setupMock      func(*mockRepository)
+		expectedStatus int
+	}{
+		{
+			name: "valid request",
+			id:   "2",
+			setupMock: func(m *mockRepository) {
+				m.deactivateFunc = func(ctx context.Context, id int) (OperationType, error) {
+					return OperationType{ID: id, Description: "Purchase with installments", Direction: DirectionDebit, Active: false}, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid id",
+			id:             "0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "operation type does not exist",
+			id:   "99",
+			setupMock: func(m *mockRepository) {
+				m.deactivateFunc = func(ctx context.Context, id int) (OperationType, error) {
+					return OperationType{}, ErrNotFound
+				}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{}
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+			handler := NewHandler(validator.New(), mockRepo)
+
+			req := httptest.NewRequest(http.MethodPost, "/operation-types/"+tt.id+"/deactivate", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.Deactivate(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response Response
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				if response.Active {
+					t.Error("expected inactive operation type in response")
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,69 @@
 package operationtype
 
+// Direction tells whether transactions of an operation type take money from the account or add to it.
+type Direction string
+
+const (
+	DirectionDebit  Direction = "debit"
+	DirectionCredit Direction = "credit"
+)
+
+// Kind tells what an operation type is used for, so that the service finds the types it relies
+// on, such as the two sides of a transfer, by their metadata rather than by id.
+type Kind string
+
+const (
+	KindPurchase   Kind = "purchase"
+	KindWithdrawal Kind = "withdrawal"
+	KindPayment    Kind = "payment"
+	KindTransfer   Kind = "transfer"
+)
+
+type CreateRequest struct {
+	Description        string `json:"description" validate:"required,max=50"`
+	Direction          string `json:"direction" validate:"required,oneof=debit credit"`
+	DischargesBalance  bool   `json:"discharges_balance"`
+	AllowsInstallments bool   `json:"allows_installments"`
+	InterestRate       int    `json:"interest_rate" validate:"gte=0"` // monthly, in basis points
+	Kind               string `json:"kind" validate:"omitempty,oneof=purchase withdrawal payment transfer"`
+
+	// AllocationStrategy splits the payments of discharging types among the debts of the account,
+	// overriding the strategy of the account
+	AllocationStrategy string `json:"allocation_strategy" validate:"omitempty,oneof=oldest_first withdrawals_first highest_interest_first proportional"`
+}
+
+type Response struct {
+	ID                 int    `json:"operation_type_id"`
+	Description        string `json:"description"`
+	Direction          string `json:"direction"`
+	DischargesBalance  bool   `json:"discharges_balance"`
+	AllowsInstallments bool   `json:"allows_installments"`
+	InterestRate       int    `json:"interest_rate"`
+	Kind               string `json:"kind,omitempty"`
+	AllocationStrategy string `json:"allocation_strategy,omitempty"`
+	Active             bool   `json:"active"`
+}
+
+type ListResponse struct {
+	Data []Response `json:"data"`
+}
+
 type OperationType struct {
-	ID          int
-	Description string
+	ID                 int
+	Description        string
+	Direction          Direction
+	DischargesBalance  bool // credits that pay off open debts, split by the allocation strategy, before keeping the remainder
+	AllowsInstallments bool // debits that can be split into monthly installments
+	Active             bool // inactive types are kept for existing transactions but cannot be used for new ones
+	InterestRate       int  // monthly, in basis points, ranks debts for the highest interest first strategy
+	Kind               Kind // empty for types with no particular use
+
+	// AllocationStrategy names how the payments of a discharging type are split among the debts
+	// of the account, see transaction.AllocationStrategy. Empty defers to the account.
+	AllocationStrategy string
+}
+
+// IsDebit reports whether transactions of the operation type are stored as negative amounts.
+func (o OperationType) IsDebit() bool {
+	return o.Direction == DirectionDebit
 }
@@ -2,34 +2,261 @@ package operationtype
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Repository interface {
+	List(ctx context.Context) ([]OperationType, error)
+	GetByID(ctx context.Context, id int) (OperationType, error)
+	GetByKind(ctx context.Context, kind Kind, direction Direction) (OperationType, error)
 	Exist(ctx context.Context, id int) (bool, error)
+	Create(ctx context.Context, operationType *OperationType) error
+	Deactivate(ctx context.Context, id int) (OperationType, error)
 }
 
+// cacheTTL is how long the cached operation types are used before being reloaded, which bounds how
+// long rows changed directly in the database go unseen.
+const cacheTTL = time.Minute
+
+// pgxRepository keeps every operation type in memory, as they are read on each new transaction
+// and rarely change. The cache is loaded on first use, reloaded once older than cacheTTL and after
+// every change made through the repository.
+//
+// Each load takes a generation when it starts and its result is only kept when no later load or
+// invalidation was stored meanwhile, so overlapping loads cannot bring back an older snapshot.
 type pgxRepository struct {
 	db *pgxpool.Pool
+
+	mu         sync.RWMutex
+	cache      map[int]OperationType
+	loadedAt   time.Time
+	generation uint64 // of the cache, or of the invalidation that cleared it
+	generated  uint64 // last generation handed out, guarded by mu
 }
 
 func NewRepository(db *pgxpool.Pool) Repository {
 	return &pgxRepository{db: db}
 }
 
+func (r *pgxRepository) List(ctx context.Context) ([]OperationType, error) {
+	cache, err := r.cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	operationTypes := make([]OperationType, 0, len(cache))
+	for _, o := range cache {
+		operationTypes = append(operationTypes, o)
+	}
+	sort.Slice(operationTypes, func(i, j int) bool {
+		return operationTypes[i].ID < operationTypes[j].ID
+	})
+
+	return operationTypes, nil
+}
+
+func (r *pgxRepository) GetByID(ctx context.Context, id int) (OperationType, error) {
+	cache, err := r.cached(ctx)
+	if err != nil {
+		return OperationType{}, err
+	}
+
+	o, ok := cache[id]
+	if !ok {
+		return OperationType{}, ErrNotFound
+	}
+
+	return o, nil
+}
+
+// GetByKind returns the active operation type of the kind and direction, the oldest one when
+// there are several, or ErrNotFound when there is none.
+func (r *pgxRepository) GetByKind(ctx context.Context, kind Kind, direction Direction) (OperationType, error) {
+	operationTypes, err := r.List(ctx)
+	if err != nil {
+		return OperationType{}, err
+	}
+
+	for _, o := range operationTypes {
+		if o.Active && o.Kind == kind && o.Direction == direction {
+			return o, nil
+		}
+	}
+
+	return OperationType{}, ErrNotFound
+}
+
 func (r *pgxRepository) Exist(ctx context.Context, id int) (bool, error) {
-	query := `SELECT COUNT(id)>0 FROM operationtype WHERE id=$1;`
+	_, err := r.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check if operation type exist: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *pgxRepository) Create(ctx context.Context, o *OperationType) error {
+	query := `
+		INSERT INTO operationtype (description, direction, discharges_balance, allows_installments, interest_rate, kind,
+								   allocation_strategy)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
+		RETURNING id, active
+	`
 
-	exist := false
-	row := r.db.QueryRow(ctx, query, id)
-	err := row.Scan(
-		&exist,
+	err := r.db.QueryRow(ctx, query, o.Description, o.Direction, o.DischargesBalance, o.AllowsInstallments, o.InterestRate, o.Kind, o.AllocationStrategy).Scan(
+		&o.ID,
+		&o.Active,
 	)
 	if err != nil {
-		return false, fmt.Errorf("failed to check if operation type exist: %w", err)
+		return fmt.Errorf("failed to create operation type: %w", err)
+	}
+
+	r.reload(ctx)
+	return nil
+}
+
+func (r *pgxRepository) Deactivate(ctx context.Context, id int) (OperationType, error) {
+	query := `
+		UPDATE operationtype SET active=FALSE WHERE id=$1
+		RETURNING id, description, direction, discharges_balance, allows_installments, active, interest_rate,
+				  COALESCE(kind, ''), COALESCE(allocation_strategy, '')
+	`
+
+	var o OperationType
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&o.ID,
+		&o.Description,
+		&o.Direction,
+		&o.DischargesBalance,
+		&o.AllowsInstallments,
+		&o.Active,
+		&o.InterestRate,
+		&o.Kind,
+		&o.AllocationStrategy,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return OperationType{}, ErrNotFound
+	}
+	if err != nil {
+		return OperationType{}, fmt.Errorf("failed to deactivate operation type: %w", err)
+	}
+
+	r.reload(ctx)
+	return o, nil
+}
+
+// cached returns the cached operation types, loading them on first use and once expired.
+func (r *pgxRepository) cached(ctx context.Context) (map[int]OperationType, error) {
+	if cache := r.fresh(); cache != nil {
+		return cache, nil
+	}
+
+	return r.load(ctx)
+}
+
+// fresh returns the cache unless it is empty or expired.
+func (r *pgxRepository) fresh() map[int]OperationType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if time.Since(r.loadedAt) >= cacheTTL {
+		return nil
+	}
+	return r.cache
+}
+
+// reload refreshes the cache after a change was committed. The change stands even when the
+// cache cannot be loaded, so the error is only logged and the cache cleared, to be loaded
+// again on next use.
+func (r *pgxRepository) reload(ctx context.Context) {
+	if _, err := r.load(ctx); err != nil {
+		log.Printf("Could not reload operation types: %v", err)
+		r.invalidate()
+	}
+}
+
+// invalidate clears the cache, discarding the loads started before.
+func (r *pgxRepository) invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generated++
+	r.cache = nil
+	r.generation = r.generated
+}
+
+// nextGeneration returns the generation of a load starting now.
+func (r *pgxRepository) nextGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generated++
+	return r.generated
+}
+
+// store keeps the cache loaded by the load of the generation unless a later one was stored, and
+// returns the cache in use.
+func (r *pgxRepository) store(generation uint64, cache map[int]OperationType) map[int]OperationType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if generation > r.generation {
+		r.cache = cache
+		r.loadedAt = time.Now()
+		r.generation = generation
+	}
+	if r.cache == nil {
+		return cache
+	}
+	return r.cache
+}
+
+// load reads the operation types from the database into the cache. The cache map is replaced,
+// never modified, so maps returned by cached stay consistent.
+func (r *pgxRepository) load(ctx context.Context) (map[int]OperationType, error) {
+	generation := r.nextGeneration()
+
+	query := `
+		SELECT id, description, direction, discharges_balance, allows_installments, active, interest_rate,
+			   COALESCE(kind, ''), COALESCE(allocation_strategy, '')
+		FROM operationtype
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load operation types: %w", err)
+	}
+	defer rows.Close()
+
+	cache := make(map[int]OperationType)
+	for rows.Next() {
+		var o OperationType
+		err := rows.Scan(
+			&o.ID,
+			&o.Description,
+			&o.Direction,
+			&o.DischargesBalance,
+			&o.AllowsInstallments,
+			&o.Active,
+			&o.InterestRate,
+			&o.Kind,
+			&o.AllocationStrategy,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan operation type: %w", err)
+		}
+		cache[o.ID] = o
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load operation types: %w", err)
 	}
 
-	return exist, nil
+	return r.store(generation, cache), nil
 }
@@ -0,0 +1,63 @@
+package operationtype
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPgxRepository_Store(t *testing.T) {
+	older := map[int]OperationType{1: {ID: 1, Description: "Older"}}
+	newer := map[int]OperationType{1: {ID: 1, Description: "Newer"}}
+
+	t.Run("older load finishing last", func(t *testing.T) {
+		r := &pgxRepository{}
+		first, second := r.nextGeneration(), r.nextGeneration()
+
+		r.store(second, newer)
+		cache := r.store(first, older)
+
+		if cache[1].Description != "Newer" || r.cache[1].Description != "Newer" {
+			t.Errorf("expected the newer snapshot to be kept, got %v", r.cache)
+		}
+	})
+
+	t.Run("load started before an invalidation", func(t *testing.T) {
+		r := &pgxRepository{}
+		generation := r.nextGeneration()
+
+		r.invalidate()
+		r.store(generation, older)
+
+		if r.cache != nil {
+			t.Errorf("expected the cache to stay cleared, got %v", r.cache)
+		}
+	})
+
+	t.Run("load started after an invalidation", func(t *testing.T) {
+		r := &pgxRepository{}
+		r.invalidate()
+
+		r.store(r.nextGeneration(), newer)
+
+		if r.cache[1].Description != "Newer" {
+			t.Errorf("expected the snapshot to be kept, got %v", r.cache)
+		}
+	})
+}
+
+func TestPgxRepository_Fresh(t *testing.T) {
+	r := &pgxRepository{}
+	if cache := r.fresh(); cache != nil {
+		t.Errorf("expected nothing before the first load, got %v", cache)
+	}
+
+	r.store(r.nextGeneration(), map[int]OperationType{1: {ID: 1}})
+	if _, ok := r.fresh()[1]; !ok {
+		t.Errorf("expected the loaded operation types, got %v", r.fresh())
+	}
+
+	r.loadedAt = time.Now().Add(-cacheTTL)
+	if cache := r.fresh(); cache != nil {
+		t.Errorf("expected nothing once expired, got %v", cache)
+	}
+}
@@ -0,0 +1,122 @@
+package transaction
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Names of the allocation strategies, as set on accounts and operation types.
+const (
+	AllocationOldestFirst          = "oldest_first"
+	AllocationWithdrawalsFirst     = "withdrawals_first"
+	AllocationHighestInterestFirst = "highest_interest_first"
+	AllocationProportional         = "proportional"
+)
+
+// Allocation is the part of a payment applied to one debt.
+type Allocation struct {
+	Debt   Transaction
+	Amount int
+}
+
+// AllocationStrategy splits a payment among open debts, which are given oldest first. The
+// allocations never add up to more than the payment nor exceed what a debt owes, and debts
+// that get nothing are left out.
+type AllocationStrategy interface {
+	Allocate(debts []Transaction, payment int) []Allocation
+}
+
+// OldestFirst pays off the debts in order, each one in full before the next.
+type OldestFirst struct{}
+
+func (OldestFirst) Allocate(debts []Transaction, payment int) []Allocation {
+	return allocateInOrder(debts, payment)
+}
+
+// WithdrawalsFirst pays off withdrawals, the debts whose operation type is in Withdrawals, before
+// any other debt, oldest first within each group.
+type WithdrawalsFirst struct {
+	Withdrawals map[int]bool // by operation type id
+}
+
+func (s WithdrawalsFirst) Allocate(debts []Transaction, payment int) []Allocation {
+	group := func(t Transaction) int {
+		if s.Withdrawals[t.OperationTypeId] {
+			return 0
+		}
+		return 1
+	}
+
+	ordered := slices.Clone(debts)
+	slices.SortStableFunc(ordered, func(a, b Transaction) int {
+		return cmp.Compare(group(a), group(b))
+	})
+	return allocateInOrder(ordered, payment)
+}
+
+// HighestInterestFirst pays off the debts whose operation type has the highest interest rate
+// first, oldest first among equal rates. Operation types missing from InterestRates have none.
+type HighestInterestFirst struct {
+	InterestRates map[int]int // by operation type id
+}
+
+func (s HighestInterestFirst) Allocate(debts []Transaction, payment int) []Allocation {
+	ordered := slices.Clone(debts)
+	slices.SortStableFunc(ordered, func(a, b Transaction) int {
+		return cmp.Compare(s.InterestRates[b.OperationTypeId], s.InterestRates[a.OperationTypeId])
+	})
+	return allocateInOrder(ordered, payment)
+}
+
+// Proportional splits the payment across all debts in proportion to what each one owes. The
+// minor units lost to rounding go one by one to the oldest debts not yet paid off.
+type Proportional struct{}
+
+func (Proportional) Allocate(debts []Transaction, payment int) []Allocation {
+	owed := 0
+	for _, debt := range debts {
+		owed -= debt.Balance
+	}
+	if payment >= owed {
+		return allocateInOrder(debts, payment)
+	}
+
+	amounts := make([]int, len(debts))
+	remaining := payment
+	for i, debt := range debts {
+		amounts[i] = int(int64(payment) * int64(-debt.Balance) / int64(owed))
+		remaining -= amounts[i]
+	}
+	for i := 0; remaining > 0; i++ {
+		if amounts[i] < -debts[i].Balance {
+			amounts[i]++
+			remaining--
+		}
+	}
+
+	allocations := make([]Allocation, 0, len(debts))
+	for i, debt := range debts {
+		if amounts[i] > 0 {
+			allocations = append(allocations, Allocation{Debt: debt, Amount: amounts[i]})
+		}
+	}
+	return allocations
+}
+
+// allocateInOrder pays off the debts in the given order until the payment runs out.
+func allocateInOrder(debts []Transaction, payment int) []Allocation {
+	var allocations []Allocation
+	for _, debt := range debts {
+		if payment == 0 {
+			break
+		}
+
+		applied := min(-debt.Balance, payment)
+		if applied == 0 {
+			continue
+		}
+		payment -= applied
+		allocations = append(allocations, Allocation{Debt: debt, Amount: applied})
+	}
+	return allocations
+}
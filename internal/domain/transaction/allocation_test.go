@@ -0,0 +1,173 @@
+package transaction
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// debt returns an open debt of the operation type, identified by n.
+func debt(n byte, operationTypeId int, balance int) Transaction {
+	return Transaction{ID: pgtype.UUID{Bytes: [16]byte{n}, Valid: true}, OperationTypeId: operationTypeId, Balance: balance}
+}
+
+type allocationTest struct {
+	name     string
+	debts    []Transaction
+	payment  int
+	expected map[byte]int // amount applied to each debt
+	order    []byte       // debts in the order they are paid
+}
+
+func runAllocationTests(t *testing.T, strategy AllocationStrategy, tests []allocationTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocations := strategy.Allocate(tt.debts, tt.payment)
+
+			if len(allocations) != len(tt.order) {
+				t.Fatalf("expected %d allocations, got %d: %+v", len(tt.order), len(allocations), allocations)
+			}
+
+			total := 0
+			for i, allocation := range allocations {
+				n := allocation.Debt.ID.Bytes[0]
+				if n != tt.order[i] {
+					t.Errorf("allocation %d: expected debt %d, got %d", i, tt.order[i], n)
+				}
+				if allocation.Amount != tt.expected[n] {
+					t.Errorf("debt %d: expected %d applied, got %d", n, tt.expected[n], allocation.Amount)
+				}
+				if allocation.Amount > -allocation.Debt.Balance {
+					t.Errorf("debt %d: applied %d over its balance %d", n, allocation.Amount, allocation.Debt.Balance)
+				}
+				total += allocation.Amount
+			}
+			if total > tt.payment {
+				t.Errorf("applied %d over the payment %d", total, tt.payment)
+			}
+		})
+	}
+}
+
+func TestOldestFirst_Allocate(t *testing.T) {
+	runAllocationTests(t, OldestFirst{}, []allocationTest{
+		{
+			name:     "pays off the oldest debts in full",
+			debts:    []Transaction{debt(1, 1, -3000), debt(2, 3, -2000), debt(3, 1, -1000)},
+			payment:  4000,
+			expected: map[byte]int{1: 3000, 2: 1000},
+			order:    []byte{1, 2},
+		},
+		{
+			name:     "payment covering every debt",
+			debts:    []Transaction{debt(1, 1, -3000), debt(2, 3, -2000)},
+			payment:  9000,
+			expected: map[byte]int{1: 3000, 2: 2000},
+			order:    []byte{1, 2},
+		},
+		{
+			name:     "no open debts",
+			debts:    []Transaction{},
+			payment:  1000,
+			expected: map[byte]int{},
+			order:    []byte{},
+		},
+	})
+}
+
+func TestWithdrawalsFirst_Allocate(t *testing.T) {
+	strategy := WithdrawalsFirst{Withdrawals: map[int]bool{3: true, 7: true}}
+
+	runAllocationTests(t, strategy, []allocationTest{
+		{
+			name:     "pays off withdrawals before older purchases",
+			debts:    []Transaction{debt(1, 1, -3000), debt(2, 3, -2000), debt(3, 1, -1000)},
+			payment:  4000,
+			expected: map[byte]int{2: 2000, 1: 2000},
+			order:    []byte{2, 1},
+		},
+		{
+			name:     "oldest first among withdrawals",
+			debts:    []Transaction{debt(1, 3, -3000), debt(2, 1, -2000), debt(3, 7, -1000)},
+			payment:  3500,
+			expected: map[byte]int{1: 3000, 3: 500},
+			order:    []byte{1, 3},
+		},
+		{
+			name:     "without withdrawals",
+			debts:    []Transaction{debt(1, 1, -3000), debt(2, 2, -2000)},
+			payment:  4000,
+			expected: map[byte]int{1: 3000, 2: 1000},
+			order:    []byte{1, 2},
+		},
+	})
+}
+
+func TestHighestInterestFirst_Allocate(t *testing.T) {
+	strategy := HighestInterestFirst{InterestRates: map[int]int{1: 1200, 2: 900, 3: 1500}}
+
+	runAllocationTests(t, strategy, []allocationTest{
+		{
+			name:     "pays off the highest rates first",
+			debts:    []Transaction{debt(1, 2, -3000), debt(2, 1, -2000), debt(3, 3, -1000)},
+			payment:  4000,
+			expected: map[byte]int{3: 1000, 2: 2000, 1: 1000},
+			order:    []byte{3, 2, 1},
+		},
+		{
+			name:     "oldest first among equal rates",
+			debts:    []Transaction{debt(1, 1, -3000), debt(2, 2, -2000), debt(3, 1, -1000)},
+			payment:  3500,
+			expected: map[byte]int{1: 3000, 3: 500},
+			order:    []byte{1, 3},
+		},
+		{
+			name:     "operation types without a rate go last",
+			debts:    []Transaction{debt(1, 9, -3000), debt(2, 2, -2000)},
+			payment:  2500,
+			expected: map[byte]int{2: 2000, 1: 500},
+			order:    []byte{2, 1},
+		},
+	})
+}
+
+func TestProportional_Allocate(t *testing.T) {
+	runAllocationTests(t, Proportional{}, []allocationTest{
+		{
+			name:     "splits in proportion to what each debt owes",
+			debts:    []Transaction{debt(1, 1, -6000), debt(2, 3, -3000), debt(3, 1, -1000)},
+			payment:  5000,
+			expected: map[byte]int{1: 3000, 2: 1500, 3: 500},
+			order:    []byte{1, 2, 3},
+		},
+		{
+			name:     "rounding remainder goes to the oldest debts",
+			debts:    []Transaction{debt(1, 1, -1000), debt(2, 1, -1000), debt(3, 1, -1000)},
+			payment:  1000,
+			expected: map[byte]int{1: 334, 2: 333, 3: 333},
+			order:    []byte{1, 2, 3},
+		},
+		{
+			name:     "debts too small for a share are left out",
+			debts:    []Transaction{debt(1, 1, -100000), debt(2, 1, -1)},
+			payment:  50,
+			expected: map[byte]int{1: 50},
+			order:    []byte{1},
+		},
+		{
+			name:     "payment covering every debt",
+			debts:    []Transaction{debt(1, 1, -3000), debt(2, 3, -2000)},
+			payment:  9000,
+			expected: map[byte]int{1: 3000, 2: 2000},
+			order:    []byte{1, 2},
+		},
+		{
+			name:     "no open debts",
+			debts:    []Transaction{},
+			payment:  1000,
+			expected: map[byte]int{},
+			order:    []byte{},
+		},
+	})
+}
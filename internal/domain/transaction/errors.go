@@ -0,0 +1,16 @@
+package transaction
+
+import "errors"
+
+// ErrNotFound is returned by the repository when the transaction does not exist.
+var ErrNotFound = errors.New("transaction not found")
+
+// ErrTransferNotFound is returned by the repository when the transfer does not exist.
+var ErrTransferNotFound = errors.New("transfer not found")
+
+// ErrNotReversible is returned when reversing a reversal, an installment purchase, whose
+// installments are reversed one by one instead, or one side of a transfer.
+var ErrNotReversible = errors.New("transaction cannot be reversed")
+
+// ErrReversalExceedsAmount is returned when a reversal exceeds what is left to reverse of the transaction.
+var ErrReversalExceedsAmount = errors.New("reversal exceeds the amount left to reverse")
@@ -1,31 +1,43 @@
 package transaction
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rikw22/challenge-money/internal/common/database"
 	"github.com/rikw22/challenge-money/internal/domain/account"
 	"github.com/rikw22/challenge-money/internal/domain/operationtype"
 	"github.com/rikw22/challenge-money/pkg/httperrors"
+	"github.com/rikw22/challenge-money/pkg/money"
 	"github.com/rikw22/challenge-money/pkg/validators"
 )
 
 type Handler struct {
 	validate                *validator.Validate
+	unitOfWork              database.UnitOfWork
 	repository              Repository
 	accountRepository       account.Repository
 	operationtypeRepository operationtype.Repository
 }
 
-func NewHandler(validate *validator.Validate, repository Repository, accountRepository account.Repository, operationtypeRepository operationtype.Repository) *Handler {
-	validate.RegisterValidation("max2decimals", validators.MaxTwoDecimals)
+func NewHandler(validate *validator.Validate, unitOfWork database.UnitOfWork, repository Repository, accountRepository account.Repository, operationtypeRepository operationtype.Repository) *Handler {
+	validate.RegisterCustomTypeFunc(money.ValidationValue, money.Decimal{})
+	validate.RegisterValidation("currency", validators.Currency)
 	return &Handler{
 		validate:                validate,
+		unitOfWork:              unitOfWork,
 		repository:              repository,
 		accountRepository:       accountRepository,
 		operationtypeRepository: operationtypeRepository,
@@ -45,55 +57,94 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate Account ID
-	exists, err := h.accountRepository.Exist(r.Context(), input.AccountId)
-	if err != nil {
-		render.Render(w, r, httperrors.ErrInternalServer(err))
+	acc, err := h.accountRepository.GetByID(r.Context(), input.AccountId)
+	if errors.Is(err, account.ErrNotFound) {
+		render.Render(w, r, httperrors.ErrInvalidRequest(fmt.Errorf("account with id %d does not exist", input.AccountId)).WithCode(httperrors.CodeAccountNotFound))
 		return
 	}
-	if !exists {
-		render.Render(w, r, httperrors.ErrInvalidRequest(fmt.Errorf("account with id %d does not exist", input.AccountId)))
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
 		return
 	}
 
 	// Validate OperationTypeId
-	operationTypeExists, err := h.operationtypeRepository.Exist(r.Context(), input.OperationTypeId)
+	operationType, err := h.operationtypeRepository.GetByID(r.Context(), input.OperationTypeId)
+	if errors.Is(err, operationtype.ErrNotFound) {
+		render.Render(w, r, httperrors.ErrInvalidRequest(fmt.Errorf("operation type with id %d does not exist", input.OperationTypeId)).WithCode(httperrors.CodeOperationTypeUnknown))
+		return
+	}
 	if err != nil {
 		render.Render(w, r, httperrors.ErrInternalServer(err))
 		return
 	}
-	if !operationTypeExists {
-		render.Render(w, r, httperrors.ErrInvalidRequest(fmt.Errorf("operation type with id %d does not exist", input.OperationTypeId)))
+	if !operationType.Active {
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(fmt.Errorf("operation type with id %d is not active", input.OperationTypeId)).WithCode(httperrors.CodeOperationTypeInactive))
 		return
 	}
 
-	// Update the balance
-	if input.OperationTypeId == 4 {
-		inputAmountInt := int(input.Amount * 100)
-		if err := h.dischargeNegativeBalances(r.Context(), input.AccountId, inputAmountInt); err != nil {
-			render.Render(w, r, httperrors.ErrInternalServer(err))
-			return
-		}
-	}
-
 	// Create the transaction
 	var t Transaction
 	t.AccountId = input.AccountId
 	t.OperationTypeId = input.OperationTypeId
+	t.Currency = acc.Currency
+	if input.Currency != "" {
+		t.Currency = money.Currency(input.Currency)
+	}
+
+	// Debits are settled in the currency of the account
+	if operationType.IsDebit() && t.Currency != acc.Currency {
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(fmt.Errorf("debits must be in the account currency %s", acc.Currency)).
+			WithCode(httperrors.CodeCurrencyMismatch).
+			WithMeta("account_currency", acc.Currency))
+		return
+	}
 
-	amount := int(input.Amount * 100)
+	minorUnits, err := input.Amount.MinorUnits(t.Currency)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	installments := max(input.Installments, 1)
+	if installments > 1 && !operationType.AllowsInstallments {
+		render.Render(w, r, httperrors.ErrInvalidRequest(fmt.Errorf("operation type with id %d does not allow installments", input.OperationTypeId)))
+		return
+	}
+	if int(minorUnits) < installments {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("amount is too small for the number of installments")))
+		return
+	}
+
+	amount := int(minorUnits)
 	storedAmount := amount
-	// Purchases and withdrawals should be stored as negative
-	if t.OperationTypeId >= 1 && t.OperationTypeId <= 3 {
-		if amount > 0 {
-			storedAmount = -amount
-		}
+	// Debits should be stored as negative
+	if operationType.IsDebit() {
+		storedAmount = -amount
 	}
 	t.Amount = storedAmount
 	t.EventDate = time.Now()
 
-	err = h.repository.Create(r.Context(), &t)
+	// Discharge and insert run in one database transaction, with the account locked
+	// so concurrent transactions of the same account are applied one at a time
+	var children []Transaction
+	err = h.unitOfWork.Do(r.Context(), func(ctx context.Context) error {
+		if err := h.repository.LockAccount(ctx, t.AccountId); err != nil {
+			return err
+		}
+
+		if installments > 1 {
+			if _, err := h.checkAccount(ctx, t.AccountId, operationType); err != nil {
+				return err
+			}
+			var err error
+			children, err = h.createInstallments(ctx, &t, amount, installments)
+			return err
+		}
+
+		return h.post(ctx, operationType, &t, amount)
+	})
 	if err != nil {
-		render.Render(w, r, httperrors.ErrInternalServer(err))
+		renderError(w, r, err)
 		return
 	}
 
@@ -103,44 +154,1107 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	installmentResponses, err := newInstallmentResponses(children)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, &CreateTransactionResponse{
 		ID:              responseID,
 		AccountId:       t.AccountId,
 		OperationTypeId: t.OperationTypeId,
-		Amount:          float64(amount) / 100,
+		Amount:          toDecimal(amount, t.Currency),
+		Currency:        string(t.Currency),
+		Installments:    installmentResponses,
+	})
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	var id pgtype.UUID
+	if err := id.Scan(chi.URLParam(r, "id")); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("transaction id must be a UUID")))
+		return
+	}
+
+	t, err := h.repository.GetByID(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeTransactionNotFound))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	item, err := newTransactionResponse(t)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	var installmentResponses []InstallmentResponse
+	if t.Installments > 1 && !t.ParentID.Valid {
+		children, err := h.repository.GetInstallments(r.Context(), t.ID)
+		if err != nil {
+			render.Render(w, r, httperrors.ErrInternalServer(err))
+			return
+		}
+		if installmentResponses, err = newInstallmentResponses(children); err != nil {
+			render.Render(w, r, httperrors.ErrInternalServer(err))
+			return
+		}
+	}
+
+	render.JSON(w, r, &GetTransactionResponse{
+		ID:                       item.ID,
+		AccountId:                item.AccountId,
+		OperationTypeId:          item.OperationTypeId,
+		OperationTypeDescription: t.OperationTypeDescription,
+		Amount:                   item.Amount,
+		Balance:                  item.Balance,
+		Currency:                 item.Currency,
+		EventDate:                item.EventDate,
+		ReversalOf:               item.ReversalOf,
+		TransferID:               item.TransferID,
+		Installments:             installmentResponses,
 	})
 }
 
-func (h *Handler) dischargeNegativeBalances(ctx context.Context, accountId int, paymentAmount int) error {
-	transactionsWithNegativeBalance, err := h.repository.GetTransactionsWithNegativeBalance(ctx, accountId)
+// Settlements lists how the balance of a transaction was settled: the debts a payment paid off,
+// or the payments that settled a debt.
+func (h *Handler) Settlements(w http.ResponseWriter, r *http.Request) {
+	var id pgtype.UUID
+	if err := id.Scan(chi.URLParam(r, "id")); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("transaction id must be a UUID")))
+		return
+	}
+
+	t, err := h.repository.GetByID(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeTransactionNotFound))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	settlements, err := h.repository.GetSettlements(r.Context(), id)
 	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	items := make([]SettlementResponse, 0, len(settlements))
+	for _, s := range settlements {
+		items = append(items, SettlementResponse{
+			ID:        uuid.UUID(s.ID.Bytes),
+			PaymentID: uuid.UUID(s.PaymentID.Bytes),
+			DebitID:   uuid.UUID(s.DebitID.Bytes),
+			Amount:    toDecimal(s.Amount, t.Currency),
+			Currency:  string(t.Currency),
+			SettledAt: s.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	render.JSON(w, r, &ListSettlementsResponse{
+		TransactionID: uuid.UUID(t.ID.Bytes),
+		Data:          items,
+	})
+}
+
+// Reverse creates a transaction compensating all or part of another one. Reversing a debit
+// first pays back its own open balance and discharges other debts with the rest, like a
+// payment split by the allocation strategy of the account; reversing a credit first takes
+// back its unused balance and owes the rest, like a debit, limit included. Reversals cannot
+// be reversed, and together cannot exceed the reversed amount.
+func (h *Handler) Reverse(w http.ResponseWriter, r *http.Request) {
+	var id pgtype.UUID
+	if err := id.Scan(chi.URLParam(r, "id")); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("transaction id must be a UUID")))
+		return
+	}
+
+	// The body is optional, an empty one reverses the whole transaction
+	var input ReverseTransactionRequest
+	if err := render.DecodeJSON(r.Body, &input); err != nil && !errors.Is(err, io.EOF) {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.validate.Struct(&input); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	original, err := h.repository.GetByID(r.Context(), id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+	if original.ReversalOf.Valid || (original.Installments > 1 && !original.ParentID.Valid) || original.TransferID.Valid {
+		renderError(w, r, ErrNotReversible)
+		return
+	}
+
+	minorUnits, err := input.Amount.MinorUnits(original.Currency)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+	amount := int(minorUnits)
+
+	reversal := Transaction{
+		AccountId:       original.AccountId,
+		OperationTypeId: original.OperationTypeId,
+		Currency:        original.Currency,
+		EventDate:       time.Now(),
+		ReversalOf:      original.ID,
+	}
+
+	err = h.unitOfWork.Do(r.Context(), func(ctx context.Context) error {
+		if err := h.repository.LockAccount(ctx, original.AccountId); err != nil {
+			return err
+		}
+
+		// Read the transaction again now that the account is locked, its balance may have changed
+		original, err := h.repository.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// Reversing a credit is a debit, and reversing a debit settles debt
+		acc, err := h.accountRepository.GetByID(ctx, original.AccountId)
+		if err != nil {
+			return err
+		}
+		if err := acc.CheckTransaction(original.Amount > 0, original.Amount < 0); err != nil {
+			return err
+		}
+
+		reversed, err := h.repository.SumReversals(ctx, original.ID)
+		if err != nil {
+			return err
+		}
+		left := int(money.Amount(original.Amount).Abs()) - reversed
+		if amount == 0 {
+			amount = left
+		}
+		if amount == 0 || amount > left {
+			return fmt.Errorf("%w: %s left to reverse", ErrReversalExceedsAmount, toDecimal(left, original.Currency))
+		}
+
+		reversal.Amount = -amount
+		if original.Amount < 0 {
+			reversal.Amount = amount
+		}
+		reversal.Balance = reversal.Amount
+		if err := h.repository.Create(ctx, &reversal); err != nil {
+			return err
+		}
+
+		if original.Amount < 0 {
+			repaid := min(amount, -original.Balance)
+			if repaid > 0 {
+				if err := h.repository.Settle(ctx, original.ID, reversal.ID, repaid); err != nil {
+					return err
+				}
+			}
+
+			remaining := amount - repaid
+			if remaining > 0 {
+				strategy, err := h.allocationStrategy(ctx, acc, operationtype.OperationType{})
+				if err != nil {
+					return err
+				}
+				if remaining, err = h.dischargeNegativeBalances(ctx, reversal, remaining, strategy); err != nil {
+					return err
+				}
+			}
+			if remaining > 0 && acc.Status == account.StatusClosed {
+				return closedAccountCreditError(amount-remaining, original.Currency)
+			}
+
+			// Debits are always in the account currency, so all that was paid off restores the limit
+			if paidOff := amount - remaining; paidOff > 0 {
+				if err := h.accountRepository.RestoreLimit(ctx, original.AccountId, paidOff); err != nil {
+					return err
+				}
+			}
+
+			reversal.Balance = remaining
+		} else {
+			takenBack := min(amount, original.Balance)
+			if takenBack > 0 {
+				if err := h.repository.Settle(ctx, reversal.ID, original.ID, takenBack); err != nil {
+					return err
+				}
+			}
+
+			owed := amount - takenBack
+			if owed > 0 {
+				if owed, err = h.applyAvailableCredit(ctx, reversal, owed); err != nil {
+					return err
+				}
+			}
+
+			if owed > 0 && original.Currency == acc.Currency {
+				if err := h.accountRepository.ReserveLimit(ctx, original.AccountId, owed); err != nil {
+					return err
+				}
+			}
+
+			reversal.Balance = -owed
+		}
+
+		return nil
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	response, err := newTransactionResponse(reversal)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, &response)
+}
+
+// Transfer moves an amount from the source to the destination account, which must share their
+// currency, with a debit on the source and a credit on the destination, of the active debit and
+// credit operation types of the transfer kind. Both are posted like any other transaction of
+// their operation type, so the debit takes from the limit of the source and the credit
+// discharges the debts of the destination, and are stored in one database transaction linked
+// by the transfer id.
+func (h *Handler) Transfer(w http.ResponseWriter, r *http.Request) {
+	var input CreateTransferRequest
+	if err := render.DecodeJSON(r.Body, &input); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.validate.Struct(&input); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	accounts := make([]account.Account, 0, 2)
+	for _, accountId := range []int{input.SourceAccountId, input.DestinationAccountId} {
+		acc, err := h.accountRepository.GetByID(r.Context(), accountId)
+		if errors.Is(err, account.ErrNotFound) {
+			render.Render(w, r, httperrors.ErrInvalidRequest(fmt.Errorf("account with id %d does not exist", accountId)).WithCode(httperrors.CodeAccountNotFound))
+			return
+		}
+		if err != nil {
+			render.Render(w, r, httperrors.ErrInternalServer(err))
+			return
+		}
+		accounts = append(accounts, acc)
+	}
+	source, destination := accounts[0], accounts[1]
+
+	if source.Currency != destination.Currency {
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(fmt.Errorf("transfers must be between accounts of the same currency, the source account is in %s", source.Currency)).
+			WithCode(httperrors.CodeCurrencyMismatch).
+			WithMeta("account_currency", source.Currency))
+		return
+	}
+
+	operationTypes := make([]operationtype.OperationType, 0, 2)
+	for _, direction := range []operationtype.Direction{operationtype.DirectionDebit, operationtype.DirectionCredit} {
+		operationType, err := h.operationtypeRepository.GetByKind(r.Context(), operationtype.KindTransfer, direction)
+		if errors.Is(err, operationtype.ErrNotFound) {
+			render.Render(w, r, httperrors.ErrUnprocessableEntity(fmt.Errorf("there is no active %s operation type of the transfer kind", direction)).WithCode(httperrors.CodeOperationTypeUnavailable))
+			return
+		}
+		if err != nil {
+			render.Render(w, r, httperrors.ErrInternalServer(err))
+			return
+		}
+		operationTypes = append(operationTypes, operationType)
+	}
+	debitType, creditType := operationTypes[0], operationTypes[1]
+
+	minorUnits, err := input.Amount.MinorUnits(source.Currency)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+	amount := int(minorUnits)
+
+	// Both legs are due at once, dated by the service clock like any other transaction
+	now := time.Now()
+	transfer := Transfer{
+		SourceAccountId:      source.ID,
+		DestinationAccountId: destination.ID,
+		Amount:               amount,
+		Currency:             source.Currency,
+		CreatedAt:            now,
+	}
+	var debit, credit Transaction
+
+	err = h.unitOfWork.Do(r.Context(), func(ctx context.Context) error {
+		// Lock in id order, so opposite transfers between the same accounts cannot deadlock
+		for _, accountId := range []int{min(source.ID, destination.ID), max(source.ID, destination.ID)} {
+			if err := h.repository.LockAccount(ctx, accountId); err != nil {
+				return err
+			}
+		}
+
+		if err := h.repository.CreateTransfer(ctx, &transfer); err != nil {
+			return err
+		}
+
+		debit = Transaction{
+			AccountId:       source.ID,
+			OperationTypeId: debitType.ID,
+			Amount:          -amount,
+			Currency:        transfer.Currency,
+			EventDate:       now,
+			TransferID:      transfer.ID,
+		}
+		if err := h.post(ctx, debitType, &debit, amount); err != nil {
+			return err
+		}
+
+		credit = Transaction{
+			AccountId:       destination.ID,
+			OperationTypeId: creditType.ID,
+			Amount:          amount,
+			Currency:        transfer.Currency,
+			EventDate:       now,
+			TransferID:      transfer.ID,
+		}
+		return h.post(ctx, creditType, &credit, amount)
+	})
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	response, err := newTransferResponse(transfer, []Transaction{debit, credit})
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, &response)
+}
+
+func (h *Handler) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	var id pgtype.UUID
+	if err := id.Scan(chi.URLParam(r, "id")); err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("transfer id must be a UUID")))
+		return
+	}
+
+	transfer, err := h.repository.GetTransfer(r.Context(), id)
+	if err != nil {
+		renderError(w, r, err)
+		return
+	}
+
+	transactions, err := h.repository.GetByTransfer(r.Context(), id)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	response, err := newTransferResponse(transfer, transactions)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, &response)
+}
+
+// renderError translates the errors of creating or reversing a transaction into HTTP error responses.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeTransactionNotFound))
+	case errors.Is(err, ErrTransferNotFound):
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeTransferNotFound))
+	case errors.Is(err, ErrNotReversible):
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(err).WithCode(httperrors.CodeReversalNotAllowed))
+	case errors.Is(err, ErrReversalExceedsAmount):
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(err).WithCode(httperrors.CodeReversalExceedsAmount))
+	case errors.Is(err, account.ErrInsufficientLimit):
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(err).WithCode(httperrors.CodeInsufficientLimit))
+	case errors.Is(err, account.ErrBlocked):
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(err).WithCode(httperrors.CodeAccountBlocked))
+	case errors.Is(err, account.ErrClosed):
+		render.Render(w, r, httperrors.ErrUnprocessableEntity(err).WithCode(httperrors.CodeAccountClosed))
+	default:
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+	}
+}
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	accountId, err := parseAccountId(r)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	acc, err := h.accountRepository.GetByID(r.Context(), accountId)
+	if errors.Is(err, account.ErrNotFound) {
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeAccountNotFound))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	filter, err := parseListFilter(r, acc.Currency)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+	filter.AccountId = accountId
+
+	// Fetch one extra row to know whether there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+
+	transactions, err := h.repository.List(r.Context(), filter)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	response := ListTransactionsResponse{Data: make([]TransactionResponse, 0, pageSize)}
+	if len(transactions) > pageSize {
+		transactions = transactions[:pageSize]
+		response.NextCursor = transactions[pageSize-1].ID.String()
+	}
+
+	for _, t := range transactions {
+		item, err := newTransactionResponse(t)
+		if err != nil {
+			render.Render(w, r, httperrors.ErrInternalServer(err))
+			return
+		}
+		response.Data = append(response.Data, item)
+	}
+
+	render.JSON(w, r, &response)
+}
+
+func (h *Handler) Balance(w http.ResponseWriter, r *http.Request) {
+	accountId, err := parseAccountId(r)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	exists, err := h.accountRepository.Exist(r.Context(), accountId)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+	if !exists {
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeAccountNotFound))
+		return
+	}
+
+	balances, err := h.repository.GetBalance(r.Context(), accountId, time.Now())
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	response := BalanceResponse{
+		AccountId: accountId,
+		Balances:  make([]CurrencyBalanceResponse, 0, len(balances)),
+	}
+	for _, balance := range balances {
+		item := CurrencyBalanceResponse{
+			Currency:        string(balance.Currency),
+			OutstandingDebt: toDecimal(balance.OutstandingDebt, balance.Currency),
+			ScheduledDebt:   toDecimal(balance.ScheduledDebt, balance.Currency),
+			AvailableCredit: toDecimal(balance.AvailableCredit, balance.Currency),
+			Totals:          make([]OperationTypeTotalResponse, 0, len(balance.Totals)),
+		}
+		for _, total := range balance.Totals {
+			item.Totals = append(item.Totals, OperationTypeTotalResponse{
+				OperationTypeId: total.OperationTypeId,
+				Count:           total.Count,
+				Amount:          toDecimal(total.Amount, balance.Currency),
+			})
+		}
+		response.Balances = append(response.Balances, item)
+	}
+
+	render.JSON(w, r, &response)
+}
+
+// Statement lists the transactions of a period between the account position before it
+// (opening balance) and after it (closing balance), the position being the sum of the amounts.
+// Each statement covers one currency, the account currency unless the currency parameter is set.
+func (h *Handler) Statement(w http.ResponseWriter, r *http.Request) {
+	accountId, err := parseAccountId(r)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(err))
+		return
+	}
+
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("from must be a RFC 3339 date")))
+		return
+	}
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("to must be a RFC 3339 date")))
+		return
+	}
+	if !to.After(from) {
+		render.Render(w, r, httperrors.ErrInvalidRequest(errors.New("to must be after from")))
+		return
+	}
+
+	acc, err := h.accountRepository.GetByID(r.Context(), accountId)
+	if errors.Is(err, account.ErrNotFound) {
+		render.Render(w, r, httperrors.ErrNotFound.WithCode(httperrors.CodeAccountNotFound))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	currency := acc.Currency
+	if v := r.URL.Query().Get("currency"); v != "" {
+		if currency, err = money.ParseCurrency(v); err != nil {
+			render.Render(w, r, httperrors.ErrInvalidRequest(err))
+			return
+		}
+	}
+
+	openingBalance, err := h.repository.SumAmountsBefore(r.Context(), accountId, currency, from)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	transactions, err := h.repository.GetByPeriod(r.Context(), accountId, currency, from, to)
+	if err != nil {
+		render.Render(w, r, httperrors.ErrInternalServer(err))
+		return
+	}
+
+	response := StatementResponse{
+		AccountId:      accountId,
+		From:           from.Format(time.RFC3339),
+		To:             to.Format(time.RFC3339),
+		Currency:       string(currency),
+		OpeningBalance: toDecimal(openingBalance, currency),
+		Items:          make([]StatementItemResponse, 0, len(transactions)),
+	}
+
+	runningBalance := openingBalance
+	for _, t := range transactions {
+		id, err := uuid.FromBytes(t.ID.Bytes[:])
+		if err != nil {
+			render.Render(w, r, httperrors.ErrInternalServer(err))
+			return
+		}
+
+		runningBalance += t.Amount
+		response.Items = append(response.Items, StatementItemResponse{
+			ID:              id,
+			OperationTypeId: t.OperationTypeId,
+			Amount:          toDecimal(t.Amount, currency),
+			RunningBalance:  toDecimal(runningBalance, currency),
+			EventDate:       t.EventDate.Format(time.RFC3339),
+		})
+	}
+	response.ClosingBalance = toDecimal(runningBalance, currency)
+
+	render.JSON(w, r, &response)
+}
+
+func parseAccountId(r *http.Request) (int, error) {
+	accountId, err := strconv.Atoi(chi.URLParam(r, "accountId"))
+	if err != nil || accountId <= 0 {
+		return 0, errors.New("account id must be a positive integer")
+	}
+	return accountId, nil
+}
+
+// parseListFilter reads the list query parameters. Amount ranges are in the currency parameter,
+// or in the account currency when it is not set.
+func parseListFilter(r *http.Request, accountCurrency money.Currency) (ListFilter, error) {
+	query := r.URL.Query()
+	filter := ListFilter{Limit: defaultListLimit}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return ListFilter{}, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		if err := filter.Cursor.Scan(v); err != nil {
+			return ListFilter{}, errors.New("cursor is invalid")
+		}
+	}
+
+	if v := query.Get("operation_type_id"); v != "" {
+		operationTypeId, err := strconv.Atoi(v)
+		if err != nil || operationTypeId <= 0 {
+			return ListFilter{}, errors.New("operation_type_id must be a positive integer")
+		}
+		filter.OperationTypeId = operationTypeId
+	}
+
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(param); v != "" {
+			date, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return ListFilter{}, fmt.Errorf("%s must be a RFC 3339 date", param)
+			}
+			*target = &date
+		}
+	}
+
+	currency := accountCurrency
+	if v := query.Get("currency"); v != "" {
+		var err error
+		if currency, err = money.ParseCurrency(v); err != nil {
+			return ListFilter{}, err
+		}
+		filter.Currency = currency
+	}
+
+	for param, target := range map[string]**int{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if v := query.Get(param); v != "" {
+			decimal, err := money.ParseDecimal(v)
+			var amount money.Amount
+			if err == nil {
+				amount, err = decimal.MinorUnits(currency)
+			}
+			if err != nil || amount < 0 {
+				return ListFilter{}, fmt.Errorf("%s must be a positive amount with at most %d decimal places", param, currency.Exponent())
+			}
+			units := int(amount)
+			*target = &units
+			// The amounts are in minor units of that currency only
+			filter.Currency = currency
+		}
+	}
+
+	return filter, nil
+}
+
+func newTransactionResponse(t Transaction) (TransactionResponse, error) {
+	id, err := uuid.FromBytes(t.ID.Bytes[:])
+	if err != nil {
+		return TransactionResponse{}, err
+	}
+
+	response := TransactionResponse{
+		ID:              id,
+		AccountId:       t.AccountId,
+		OperationTypeId: t.OperationTypeId,
+		Amount:          money.ToDecimal(money.Amount(t.Amount).Abs(), t.Currency),
+		Balance:         toDecimal(t.Balance, t.Currency),
+		Currency:        string(t.Currency),
+		EventDate:       t.EventDate.Format(time.RFC3339),
+	}
+	if t.ReversalOf.Valid {
+		reversalOf := uuid.UUID(t.ReversalOf.Bytes)
+		response.ReversalOf = &reversalOf
+	}
+	if t.TransferID.Valid {
+		transferID := uuid.UUID(t.TransferID.Bytes)
+		response.TransferID = &transferID
+	}
+
+	return response, nil
+}
+
+func newTransferResponse(transfer Transfer, transactions []Transaction) (TransferResponse, error) {
+	id, err := uuid.FromBytes(transfer.ID.Bytes[:])
+	if err != nil {
+		return TransferResponse{}, err
+	}
+
+	items := make([]TransactionResponse, 0, len(transactions))
+	for _, t := range transactions {
+		item, err := newTransactionResponse(t)
+		if err != nil {
+			return TransferResponse{}, err
+		}
+		items = append(items, item)
+	}
+
+	return TransferResponse{
+		ID:                   id,
+		SourceAccountId:      transfer.SourceAccountId,
+		DestinationAccountId: transfer.DestinationAccountId,
+		Amount:               toDecimal(transfer.Amount, transfer.Currency),
+		Currency:             string(transfer.Currency),
+		CreatedAt:            transfer.CreatedAt.Format(time.RFC3339),
+		Transactions:         items,
+	}, nil
+}
+
+func newInstallmentResponses(installments []Transaction) ([]InstallmentResponse, error) {
+	if len(installments) == 0 {
+		return nil, nil
+	}
+
+	responses := make([]InstallmentResponse, 0, len(installments))
+	for _, t := range installments {
+		id, err := uuid.FromBytes(t.ID.Bytes[:])
+		if err != nil {
+			return nil, err
+		}
+
+		responses = append(responses, InstallmentResponse{
+			ID:                id,
+			InstallmentNumber: t.InstallmentNumber,
+			Amount:            money.ToDecimal(money.Amount(t.Amount).Abs(), t.Currency),
+			Balance:           toDecimal(t.Balance, t.Currency),
+			DueDate:           t.EventDate.Format(time.RFC3339),
+		})
+	}
+
+	return responses, nil
+}
+
+// toDecimal formats an amount in minor units of the currency.
+func toDecimal(units int, currency money.Currency) money.Decimal {
+	return money.ToDecimal(money.Amount(units), currency)
+}
+
+// checkAccount returns the account once its status is known to accept a transaction of the
+// operation type. The account must be locked, so its status cannot change until the
+// transaction is stored.
+func (h *Handler) checkAccount(ctx context.Context, accountId int, operationType operationtype.OperationType) (account.Account, error) {
+	acc, err := h.accountRepository.GetByID(ctx, accountId)
+	if err != nil {
+		return account.Account{}, err
+	}
+	if err := acc.CheckTransaction(operationType.IsDebit(), operationType.DischargesBalance); err != nil {
+		return account.Account{}, err
+	}
+	return acc, nil
+}
+
+// post stores a new transaction of amount minor units on the locked account and settles it with
+// the other balances of the account. Discharging credits pay off open debts and keep what is
+// left as a positive balance, other credits are kept whole, and debits are settled first with
+// that unused credit, always of the same currency. What debits still owe takes from the
+// available limit of the account, and what credits pay off in the account currency gives it back.
+func (h *Handler) post(ctx context.Context, operationType operationtype.OperationType, t *Transaction, amount int) error {
+	acc, err := h.checkAccount(ctx, t.AccountId, operationType)
+	if err != nil {
+		return err
+	}
+
+	t.Balance = t.Amount
+	if err := h.repository.Create(ctx, t); err != nil {
 		return err
 	}
 
-	totalNegativeBalance := 0
-	for _, transaction := range transactionsWithNegativeBalance {
-		totalNegativeBalance += transaction.Balance
+	switch {
+	case operationType.IsDebit():
+		owed, err := h.applyAvailableCredit(ctx, *t, amount)
+		if err != nil {
+			return err
+		}
+		if owed > 0 {
+			if err := h.accountRepository.ReserveLimit(ctx, t.AccountId, owed); err != nil {
+				return err
+			}
+		}
+		t.Balance = -owed
+	case operationType.DischargesBalance:
+		strategy, err := h.allocationStrategy(ctx, acc, operationType)
+		if err != nil {
+			return err
+		}
+		remaining, err := h.dischargeNegativeBalances(ctx, *t, amount, strategy)
+		if err != nil {
+			return err
+		}
+		if remaining > 0 && acc.Status == account.StatusClosed {
+			return closedAccountCreditError(amount-remaining, t.Currency)
+		}
+		if paidOff := amount - remaining; paidOff > 0 && t.Currency == acc.Currency {
+			if err := h.accountRepository.RestoreLimit(ctx, t.AccountId, paidOff); err != nil {
+				return err
+			}
+		}
+		t.Balance = remaining
+	}
+
+	return nil
+}
+
+// closedAccountCreditError rejects the part of a credit to a closed account left once its due debt,
+// of which paidOff was settled, is paid off, as closed accounts only take credits settling debt.
+func closedAccountCreditError(paidOff int, currency money.Currency) error {
+	return fmt.Errorf("%w: credits cannot exceed its due debt of %s", account.ErrClosed, toDecimal(paidOff, currency))
+}
+
+// createInstallments stores an installment purchase as its parent row, which carries no balance,
+// and one row per installment, due a month apart starting with the purchase date. The amount
+// is split evenly and the remainder of the division goes to the first installment. Only the
+// first installment is due, so it is the only one settled with the available credit here, the
+// others by SettleDueInstallments when they fall due, while the limit is reserved for
+// everything still owed.
+func (h *Handler) createInstallments(ctx context.Context, parent *Transaction, amount int, count int) ([]Transaction, error) {
+	parent.Balance = 0
+	parent.Installments = count
+	if err := h.repository.Create(ctx, parent); err != nil {
+		return nil, err
 	}
 
-	if len(transactionsWithNegativeBalance) > 0 && totalNegativeBalance < 0 {
-		remainingAmount := paymentAmount
-		for _, transaction := range transactionsWithNegativeBalance {
-			amountOwed := -transaction.Balance
-			remainingAmount = remainingAmount - amountOwed
+	installments := make([]Transaction, 0, count)
+	totalOwed := 0
+	for number := 1; number <= count; number++ {
+		installmentAmount := amount / count
+		if number == 1 {
+			installmentAmount += amount % count
+		}
 
-			newBalanceValue := remainingAmount
-			if remainingAmount >= 0 {
-				newBalanceValue = 0
+		installment := Transaction{
+			AccountId:         parent.AccountId,
+			OperationTypeId:   parent.OperationTypeId,
+			Amount:            -installmentAmount,
+			Balance:           -installmentAmount,
+			Currency:          parent.Currency,
+			EventDate:         installmentDueDate(parent.EventDate, number),
+			ParentID:          parent.ID,
+			Installments:      count,
+			InstallmentNumber: number,
+		}
+		if err := h.repository.Create(ctx, &installment); err != nil {
+			return nil, err
+		}
+		if number == 1 {
+			owed, err := h.applyAvailableCredit(ctx, installment, installmentAmount)
+			if err != nil {
+				return nil, err
 			}
+			installment.Balance = -owed
+		}
+		installments = append(installments, installment)
+		totalOwed -= installment.Balance
+	}
+
+	if totalOwed > 0 {
+		if err := h.accountRepository.ReserveLimit(ctx, parent.AccountId, totalOwed); err != nil {
+			return nil, err
+		}
+	}
 
-			h.repository.UpdateTransactionBalance(ctx, transaction.ID, newBalanceValue)
+	return installments, nil
+}
+
+// installmentDueDate returns when the installment of the number falls due, a month after the previous
+// one starting with the purchase date. Months too short for the day of the purchase use their last
+// day, so a purchase on January 31 is due on the last day of February and on March 31.
+func installmentDueDate(purchase time.Time, number int) time.Time {
+	year, month, day := purchase.Date()
+	firstDay := time.Date(year, month+time.Month(number-1), 1, purchase.Hour(), purchase.Minute(), purchase.Second(), purchase.Nanosecond(), purchase.Location())
+	lastDay := firstDay.AddDate(0, 1, -1).Day()
+	return firstDay.AddDate(0, 0, min(day, lastDay)-1)
+}
 
-			if remainingAmount < 0 {
-				break
+// dischargeNegativeBalances settles paymentAmount of a stored payment with the open debts of its
+// account in the same currency that are already due, split among them by the strategy, and
+// returns the part of the payment left unused.
+func (h *Handler) dischargeNegativeBalances(ctx context.Context, payment Transaction, paymentAmount int, strategy AllocationStrategy) (int, error) {
+	transactionsWithNegativeBalance, err := h.repository.GetTransactionsWithNegativeBalance(ctx, payment.AccountId, payment.Currency, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	remainingAmount := paymentAmount
+	for _, allocation := range strategy.Allocate(transactionsWithNegativeBalance, paymentAmount) {
+		if err := h.repository.Settle(ctx, allocation.Debt.ID, payment.ID, allocation.Amount); err != nil {
+			return 0, err
+		}
+		remainingAmount -= allocation.Amount
+	}
+
+	return remainingAmount, nil
+}
+
+// SettleDueInstallments settles, every interval until ctx is done, the debts that fell due on
+// accounts holding unused credit. New installments are only settled with the available credit
+// when they are due at once, so this is how the later ones use it when their time comes.
+func (h *Handler) SettleDueInstallments(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := h.settleDueDebts(ctx, now); err != nil {
+				log.Printf("Could not settle due installments: %v", err)
+			}
+		}
+	}
+}
+
+// settleDueDebts settles the debts due by now with the unused credit of their account and
+// currency, one account at a time, carrying on with the other accounts when one fails.
+func (h *Handler) settleDueDebts(ctx context.Context, now time.Time) error {
+	accounts, err := h.repository.GetAccountsWithDueDebtsAndCredit(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, a := range accounts {
+		err := h.unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if err := h.repository.LockAccount(ctx, a.AccountId); err != nil {
+				return err
+			}
+			acc, err := h.accountRepository.GetByID(ctx, a.AccountId)
+			if err != nil {
+				return err
+			}
+			return h.applyCreditToDueDebts(ctx, acc, a.Currency, now)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", a.AccountId, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// applyCreditToDueDebts settles the debts of the locked account due by now with its unused credit
+// in the currency, spending the credits oldest first on the debts chosen by the allocation
+// strategy of the account, as a payment would. What is paid off in the account currency gives
+// the available limit back.
+func (h *Handler) applyCreditToDueDebts(ctx context.Context, acc account.Account, currency money.Currency, now time.Time) error {
+	credits, err := h.repository.GetTransactionsWithPositiveBalance(ctx, acc.ID, currency)
+	if err != nil {
+		return err
+	}
+	available := 0
+	for _, credit := range credits {
+		available += credit.Balance
+	}
+	if available == 0 {
+		return nil
+	}
+
+	debts, err := h.repository.GetTransactionsWithNegativeBalance(ctx, acc.ID, currency, now)
+	if err != nil {
+		return err
+	}
+	strategy, err := h.allocationStrategy(ctx, acc, operationtype.OperationType{})
+	if err != nil {
+		return err
+	}
+
+	paidOff := 0
+	next := 0
+	for _, allocation := range strategy.Allocate(debts, available) {
+		for remaining := allocation.Amount; remaining > 0; {
+			applied := min(credits[next].Balance, remaining)
+			if err := h.repository.Settle(ctx, allocation.Debt.ID, credits[next].ID, applied); err != nil {
+				return err
+			}
+			credits[next].Balance -= applied
+			remaining -= applied
+			paidOff += applied
+			if credits[next].Balance == 0 {
+				next++
 			}
 		}
 	}
 
+	if paidOff > 0 && currency == acc.Currency {
+		return h.accountRepository.RestoreLimit(ctx, acc.ID, paidOff)
+	}
 	return nil
 }
+
+// allocationStrategy returns the strategy splitting the payments of an operation type among the
+// debts of the account: the one of the operation type when set, otherwise the one of the
+// account, and oldest first when neither is.
+func (h *Handler) allocationStrategy(ctx context.Context, acc account.Account, operationType operationtype.OperationType) (AllocationStrategy, error) {
+	switch name := cmp.Or(operationType.AllocationStrategy, acc.AllocationStrategy, AllocationOldestFirst); name {
+	case AllocationOldestFirst:
+		return OldestFirst{}, nil
+	case AllocationWithdrawalsFirst:
+		operationTypes, err := h.operationtypeRepository.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		withdrawals := make(map[int]bool)
+		for _, o := range operationTypes {
+			if o.Kind == operationtype.KindWithdrawal {
+				withdrawals[o.ID] = true
+			}
+		}
+		return WithdrawalsFirst{Withdrawals: withdrawals}, nil
+	case AllocationProportional:
+		return Proportional{}, nil
+	case AllocationHighestInterestFirst:
+		operationTypes, err := h.operationtypeRepository.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		interestRates := make(map[int]int, len(operationTypes))
+		for _, o := range operationTypes {
+			interestRates[o.ID] = o.InterestRate
+		}
+		return HighestInterestFirst{InterestRates: interestRates}, nil
+	default:
+		return nil, fmt.Errorf("unknown allocation strategy %q", name)
+	}
+}
+
+// applyAvailableCredit settles debitAmount of a stored debit with the unused credit of its account
+// in the same currency, oldest first, and returns the part of the debit still owed.
+func (h *Handler) applyAvailableCredit(ctx context.Context, debit Transaction, debitAmount int) (int, error) {
+	transactionsWithPositiveBalance, err := h.repository.GetTransactionsWithPositiveBalance(ctx, debit.AccountId, debit.Currency)
+	if err != nil {
+		return 0, err
+	}
+
+	remainingDebt := debitAmount
+	for _, transaction := range transactionsWithPositiveBalance {
+		if remainingDebt == 0 {
+			break
+		}
+
+		applied := min(transaction.Balance, remainingDebt)
+		remainingDebt -= applied
+
+		if err := h.repository.Settle(ctx, debit.ID, transaction.ID, applied); err != nil {
+			return 0, err
+		}
+	}
+
+	return remainingDebt, nil
+}
@@ -5,21 +5,46 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"math"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/rikw22/challenge-money/internal/domain/account"
+	"github.com/rikw22/challenge-money/internal/domain/operationtype"
+	"github.com/rikw22/challenge-money/pkg/money"
 )
 
 type mockRepository struct {
+	getByIDFunc                            func(ctx context.Context, id pgtype.UUID) (Transaction, error)
 	createFunc                             func(ctx context.Context, transaction *Transaction) error
-	getTransactionsWithNegativeBalanceFunc func(ctx context.Context, accountId int) ([]Transaction, error)
-	updateTransactionBalanceFunc           func(ctx context.Context, uuid pgtype.UUID, balance int) error
+	lockAccountFunc                        func(ctx context.Context, accountId int) error
+	getTransactionsWithNegativeBalanceFunc func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error)
+	getTransactionsWithPositiveBalanceFunc func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error)
+	getAccountsWithDueDebtsAndCreditFunc   func(ctx context.Context, dueBy time.Time) ([]AccountCurrency, error)
+	settleFunc                             func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error
+	listFunc                               func(ctx context.Context, filter ListFilter) ([]Transaction, error)
+	getBalanceFunc                         func(ctx context.Context, accountId int, asOf time.Time) ([]Balance, error)
+	sumAmountsBeforeFunc                   func(ctx context.Context, accountId int, currency money.Currency, before time.Time) (int, error)
+	getByPeriodFunc                        func(ctx context.Context, accountId int, currency money.Currency, from time.Time, to time.Time) ([]Transaction, error)
+	getInstallmentsFunc                    func(ctx context.Context, parentId pgtype.UUID) ([]Transaction, error)
+	sumReversalsFunc                       func(ctx context.Context, id pgtype.UUID) (int, error)
+	getSettlementsFunc                     func(ctx context.Context, transactionId pgtype.UUID) ([]Settlement, error)
+	createTransferFunc                     func(ctx context.Context, transfer *Transfer) error
+	getTransferFunc                        func(ctx context.Context, id pgtype.UUID) (Transfer, error)
+	getByTransferFunc                      func(ctx context.Context, transferId pgtype.UUID) ([]Transaction, error)
+}
+
+func (m *mockRepository) GetByID(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+	if m.getByIDFunc != nil {
+		return m.getByIDFunc(ctx, id)
+	}
+	return Transaction{}, errors.New("not implemented")
 }
 
 func (m *mockRepository) Create(ctx context.Context, transaction *Transaction) error {
@@ -29,25 +54,138 @@ func (m *mockRepository) Create(ctx context.Context, transaction *Transaction) e
 	return errors.New("not implemented")
 }
 
-func (m *mockRepository) GetTransactionsWithNegativeBalance(ctx context.Context, accountId int) ([]Transaction, error) {
+func (m *mockRepository) LockAccount(ctx context.Context, accountId int) error {
+	if m.lockAccountFunc != nil {
+		return m.lockAccountFunc(ctx, accountId)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetTransactionsWithNegativeBalance(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
 	if m.getTransactionsWithNegativeBalanceFunc != nil {
-		return m.getTransactionsWithNegativeBalanceFunc(ctx, accountId)
+		return m.getTransactionsWithNegativeBalanceFunc(ctx, accountId, currency, dueBy)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetTransactionsWithPositiveBalance(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+	if m.getTransactionsWithPositiveBalanceFunc != nil {
+		return m.getTransactionsWithPositiveBalanceFunc(ctx, accountId, currency)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetAccountsWithDueDebtsAndCredit(ctx context.Context, dueBy time.Time) ([]AccountCurrency, error) {
+	if m.getAccountsWithDueDebtsAndCreditFunc != nil {
+		return m.getAccountsWithDueDebtsAndCreditFunc(ctx, dueBy)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) Settle(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+	if m.settleFunc != nil {
+		return m.settleFunc(ctx, debitId, creditId, amount)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *mockRepository) List(ctx context.Context, filter ListFilter) ([]Transaction, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx, filter)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetBalance(ctx context.Context, accountId int, asOf time.Time) ([]Balance, error) {
+	if m.getBalanceFunc != nil {
+		return m.getBalanceFunc(ctx, accountId, asOf)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) SumAmountsBefore(ctx context.Context, accountId int, currency money.Currency, before time.Time) (int, error) {
+	if m.sumAmountsBeforeFunc != nil {
+		return m.sumAmountsBeforeFunc(ctx, accountId, currency, before)
+	}
+	return 0, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetByPeriod(ctx context.Context, accountId int, currency money.Currency, from time.Time, to time.Time) ([]Transaction, error) {
+	if m.getByPeriodFunc != nil {
+		return m.getByPeriodFunc(ctx, accountId, currency, from, to)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetInstallments(ctx context.Context, parentId pgtype.UUID) ([]Transaction, error) {
+	if m.getInstallmentsFunc != nil {
+		return m.getInstallmentsFunc(ctx, parentId)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockRepository) SumReversals(ctx context.Context, id pgtype.UUID) (int, error) {
+	if m.sumReversalsFunc != nil {
+		return m.sumReversalsFunc(ctx, id)
+	}
+	return 0, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetSettlements(ctx context.Context, transactionId pgtype.UUID) ([]Settlement, error) {
+	if m.getSettlementsFunc != nil {
+		return m.getSettlementsFunc(ctx, transactionId)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *mockRepository) UpdateTransactionBalance(ctx context.Context, uuid pgtype.UUID, balance int) error {
-	if m.updateTransactionBalanceFunc != nil {
-		return m.updateTransactionBalanceFunc(ctx, uuid, balance)
+func (m *mockRepository) CreateTransfer(ctx context.Context, transfer *Transfer) error {
+	if m.createTransferFunc != nil {
+		return m.createTransferFunc(ctx, transfer)
 	}
 	return errors.New("not implemented")
 }
 
+func (m *mockRepository) GetTransfer(ctx context.Context, id pgtype.UUID) (Transfer, error) {
+	if m.getTransferFunc != nil {
+		return m.getTransferFunc(ctx, id)
+	}
+	return Transfer{}, errors.New("not implemented")
+}
+
+func (m *mockRepository) GetByTransfer(ctx context.Context, transferId pgtype.UUID) ([]Transaction, error) {
+	if m.getByTransferFunc != nil {
+		return m.getByTransferFunc(ctx, transferId)
+	}
+	return nil, errors.New("not implemented")
+}
+
+type mockUnitOfWork struct {
+	committed bool
+}
+
+func (m *mockUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	m.committed = true
+	return nil
+}
+
 type mockAccountRepository struct {
-	existFunc func(ctx context.Context, id int) (bool, error)
+	getByIDFunc      func(ctx context.Context, id int) (account.Account, error)
+	existFunc        func(ctx context.Context, id int) (bool, error)
+	reserveLimitFunc func(ctx context.Context, id int, amount int) error
+	restoreLimitFunc func(ctx context.Context, id int, amount int) error
 }
 
-func (m *mockAccountRepository) GetByID(ctx context.Context, id string) (account.Account, error) {
+func (m *mockAccountRepository) GetByID(ctx context.Context, id int) (account.Account, error) {
+	if m.getByIDFunc != nil {
+		return m.getByIDFunc(ctx, id)
+	}
+	return account.Account{}, errors.New("not implemented")
+}
+
+func (m *mockAccountRepository) GetByDocumentNumber(ctx context.Context, documentNumber string) (account.Account, error) {
 	return account.Account{}, errors.New("not implemented")
 }
 
@@ -55,6 +193,37 @@ func (m *mockAccountRepository) Create(ctx context.Context, acc *account.Account
 	return errors.New("not implemented")
 }
 
+func (m *mockAccountRepository) GetByIDForUpdate(ctx context.Context, id int) (account.Account, error) {
+	return account.Account{}, errors.New("not implemented")
+}
+
+func (m *mockAccountRepository) List(ctx context.Context, filter account.ListFilter) ([]account.Account, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockAccountRepository) UpdateStatus(ctx context.Context, id int, status account.Status) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockAccountRepository) CreateStatusChange(ctx context.Context, change *account.StatusChange) error {
+	return errors.New("not implemented")
+}
+
+// ReserveLimit and RestoreLimit succeed by default, so only limit tests need to set them.
+func (m *mockAccountRepository) ReserveLimit(ctx context.Context, id int, amount int) error {
+	if m.reserveLimitFunc != nil {
+		return m.reserveLimitFunc(ctx, id, amount)
+	}
+	return nil
+}
+
+func (m *mockAccountRepository) RestoreLimit(ctx context.Context, id int, amount int) error {
+	if m.restoreLimitFunc != nil {
+		return m.restoreLimitFunc(ctx, id, amount)
+	}
+	return nil
+}
+
 func (m *mockAccountRepository) Exist(ctx context.Context, id int) (bool, error) {
 	if m.existFunc != nil {
 		return m.existFunc(ctx, id)
@@ -63,7 +232,39 @@ func (m *mockAccountRepository) Exist(ctx context.Context, id int) (bool, error)
 }
 
 type mockOperationTypeRepository struct {
-	existFunc func(ctx context.Context, id int) (bool, error)
+	getByIDFunc   func(ctx context.Context, id int) (operationtype.OperationType, error)
+	getByKindFunc func(ctx context.Context, kind operationtype.Kind, direction operationtype.Direction) (operationtype.OperationType, error)
+	listFunc      func(ctx context.Context) ([]operationtype.OperationType, error)
+	existFunc     func(ctx context.Context, id int) (bool, error)
+}
+
+func (m *mockOperationTypeRepository) GetByID(ctx context.Context, id int) (operationtype.OperationType, error) {
+	if m.getByIDFunc != nil {
+		return m.getByIDFunc(ctx, id)
+	}
+	return operationtype.OperationType{}, errors.New("not implemented")
+}
+
+func (m *mockOperationTypeRepository) GetByKind(ctx context.Context, kind operationtype.Kind, direction operationtype.Direction) (operationtype.OperationType, error) {
+	if m.getByKindFunc != nil {
+		return m.getByKindFunc(ctx, kind, direction)
+	}
+	return operationtype.OperationType{}, errors.New("not implemented")
+}
+
+func (m *mockOperationTypeRepository) List(ctx context.Context) ([]operationtype.OperationType, error) {
+	if m.listFunc != nil {
+		return m.listFunc(ctx)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockOperationTypeRepository) Create(ctx context.Context, operationType *operationtype.OperationType) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockOperationTypeRepository) Deactivate(ctx context.Context, id int) (operationtype.OperationType, error) {
+	return operationtype.OperationType{}, errors.New("not implemented")
 }
 
 func (m *mockOperationTypeRepository) Exist(ctx context.Context, id int) (bool, error) {
@@ -73,6 +274,43 @@ func (m *mockOperationTypeRepository) Exist(ctx context.Context, id int) (bool,
 	return false, errors.New("not implemented")
 }
 
+// seededOperationTypes mirrors the operation types inserted by the migrations.
+var seededOperationTypes = map[int]operationtype.OperationType{
+	1: {ID: 1, Description: "Normal Purchase", Direction: operationtype.DirectionDebit, Active: true, InterestRate: 1200, Kind: operationtype.KindPurchase},
+	2: {ID: 2, Description: "Purchase with installments", Direction: operationtype.DirectionDebit, AllowsInstallments: true, Active: true, InterestRate: 900, Kind: operationtype.KindPurchase},
+	3: {ID: 3, Description: "Withdrawal", Direction: operationtype.DirectionDebit, Active: true, InterestRate: 1500, Kind: operationtype.KindWithdrawal},
+	4: {ID: 4, Description: "Credit Voucher", Direction: operationtype.DirectionCredit, DischargesBalance: true, Active: true, Kind: operationtype.KindPayment},
+	5: {ID: 5, Description: "Transfer", Direction: operationtype.DirectionDebit, Active: true, InterestRate: 1200, Kind: operationtype.KindTransfer},
+	6: {ID: 6, Description: "Transfer Received", Direction: operationtype.DirectionCredit, DischargesBalance: true, Active: true, Kind: operationtype.KindTransfer},
+}
+
+// balanceOf returns the balance of the transaction with the id among transactions.
+func balanceOf(transactions []Transaction, id pgtype.UUID) int {
+	for _, transaction := range transactions {
+		if transaction.ID == id {
+			return transaction.Balance
+		}
+	}
+	return 0
+}
+
+func getSeededOperationType(ctx context.Context, id int) (operationtype.OperationType, error) {
+	if operationType, ok := seededOperationTypes[id]; ok {
+		return operationType, nil
+	}
+	return operationtype.OperationType{}, operationtype.ErrNotFound
+}
+
+func getSeededOperationTypeByKind(ctx context.Context, kind operationtype.Kind, direction operationtype.Direction) (operationtype.OperationType, error) {
+	for id := 1; id <= len(seededOperationTypes); id++ {
+		operationType := seededOperationTypes[id]
+		if operationType.Active && operationType.Kind == kind && operationType.Direction == direction {
+			return operationType, nil
+		}
+	}
+	return operationtype.OperationType{}, operationtype.ErrNotFound
+}
+
 func TestHandler_Create(t *testing.T) {
 	tests := []struct {
 		name                   string
@@ -87,7 +325,7 @@ func TestHandler_Create(t *testing.T) {
 			body: CreateTransactionRequest{
 				AccountId:       1,
 				OperationTypeId: 4,
-				Amount:          123.45,
+				Amount:          money.ToDecimal(12345, money.DefaultCurrency),
 			},
 			setupMock: func(m *mockRepository) {
 				m.createFunc = func(ctx context.Context, t *Transaction) error {
@@ -96,19 +334,17 @@ func TestHandler_Create(t *testing.T) {
 					t.EventDate = time.Now()
 					return nil
 				}
-				m.getTransactionsWithNegativeBalanceFunc = func(ctx context.Context, accountId int) ([]Transaction, error) {
+				m.getTransactionsWithNegativeBalanceFunc = func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
 					return []Transaction{}, nil
 				}
 			},
 			setupAccountMock: func(m *mockAccountRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
 				}
 			},
 			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
-				}
+				m.getByIDFunc = getSeededOperationType
 			},
 			expectedStatus: http.StatusCreated,
 		},
@@ -117,7 +353,7 @@ func TestHandler_Create(t *testing.T) {
 			body: CreateTransactionRequest{
 				AccountId:       999999,
 				OperationTypeId: 1,
-				Amount:          50.00,
+				Amount:          money.ToDecimal(5000, money.DefaultCurrency),
 			},
 			setupMock: func(m *mockRepository) {
 				m.createFunc = func(ctx context.Context, t *Transaction) error {
@@ -125,19 +361,103 @@ func TestHandler_Create(t *testing.T) {
 					t.EventDate = time.Now()
 					return nil
 				}
+				m.getTransactionsWithPositiveBalanceFunc = func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return []Transaction{}, nil
+				}
+			},
+			setupAccountMock: func(m *mockAccountRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				}
+			},
+			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
+				m.getByIDFunc = getSeededOperationType
 			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "debit in another currency",
+			body: CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: 1,
+				Amount:          money.ToDecimal(5000, "USD"),
+				Currency:        "USD",
+			},
+			setupMock: nil,
 			setupAccountMock: func(m *mockAccountRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: "BRL"}, nil
 				}
 			},
 			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				m.getByIDFunc = getSeededOperationType
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "credit in another currency",
+			body: CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: 4,
+				Amount:          money.ToDecimal(5000, "USD"),
+				Currency:        "USD",
+			},
+			setupMock: func(m *mockRepository) {
+				m.createFunc = func(ctx context.Context, t *Transaction) error {
+					if t.Currency != "USD" || t.Amount != 5000 {
+						return errors.New("unexpected transaction")
+					}
+					t.ID = pgtype.UUID{Bytes: [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, Valid: true}
+					return nil
+				}
+				m.getTransactionsWithNegativeBalanceFunc = func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					if currency != "USD" {
+						return nil, errors.New("discharged debts of another currency")
+					}
+					return []Transaction{}, nil
+				}
+			},
+			setupAccountMock: func(m *mockAccountRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: "BRL"}, nil
 				}
 			},
+			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
+				m.getByIDFunc = getSeededOperationType
+			},
 			expectedStatus: http.StatusCreated,
 		},
+		{
+			name: "amount with decimals in a zero decimal currency",
+			body: map[string]interface{}{
+				"account_id":        1,
+				"operation_type_id": 1,
+				"amount":            "1500.50",
+			},
+			setupMock: nil,
+			setupAccountMock: func(m *mockAccountRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: "JPY"}, nil
+				}
+			},
+			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
+				m.getByIDFunc = getSeededOperationType
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "unsupported currency",
+			body: map[string]interface{}{
+				"account_id":        1,
+				"operation_type_id": 4,
+				"amount":            10,
+				"currency":          "XXX",
+			},
+			setupMock:              nil,
+			setupAccountMock:       nil,
+			setupOperationTypeMock: nil,
+			expectedStatus:         http.StatusBadRequest,
+		},
 		{
 			name:                   "empty body",
 			body:                   map[string]interface{}{},
@@ -206,12 +526,12 @@ func TestHandler_Create(t *testing.T) {
 			body: CreateTransactionRequest{
 				AccountId:       999,
 				OperationTypeId: 4,
-				Amount:          123.45,
+				Amount:          money.ToDecimal(12345, money.DefaultCurrency),
 			},
 			setupMock: nil,
 			setupAccountMock: func(m *mockAccountRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return false, nil
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{}, account.ErrNotFound
 				}
 			},
 			setupOperationTypeMock: nil,
@@ -222,12 +542,12 @@ func TestHandler_Create(t *testing.T) {
 			body: CreateTransactionRequest{
 				AccountId:       1,
 				OperationTypeId: 4,
-				Amount:          123.45,
+				Amount:          money.ToDecimal(12345, money.DefaultCurrency),
 			},
 			setupMock: nil,
 			setupAccountMock: func(m *mockAccountRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return false, errors.New("database connection error")
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{}, errors.New("database connection error")
 				}
 			},
 			setupOperationTypeMock: nil,
@@ -238,37 +558,57 @@ func TestHandler_Create(t *testing.T) {
 			body: CreateTransactionRequest{
 				AccountId:       1,
 				OperationTypeId: 5,
-				Amount:          123.45,
+				Amount:          money.ToDecimal(12345, money.DefaultCurrency),
 			},
 			setupMock: nil,
 			setupAccountMock: func(m *mockAccountRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
 				}
 			},
 			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return false, nil
+				m.getByIDFunc = func(ctx context.Context, id int) (operationtype.OperationType, error) {
+					return operationtype.OperationType{}, operationtype.ErrNotFound
 				}
 			},
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name: "operation type is not active",
+			body: CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: 5,
+				Amount:          money.ToDecimal(12345, money.DefaultCurrency),
+			},
+			setupMock: nil,
+			setupAccountMock: func(m *mockAccountRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				}
+			},
+			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (operationtype.OperationType, error) {
+					return operationtype.OperationType{ID: id, Direction: operationtype.DirectionDebit, Active: false}, nil
+				}
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
 		{
 			name: "operation type validation error",
 			body: CreateTransactionRequest{
 				AccountId:       1,
 				OperationTypeId: 4,
-				Amount:          123.45,
+				Amount:          money.ToDecimal(12345, money.DefaultCurrency),
 			},
 			setupMock: nil,
 			setupAccountMock: func(m *mockAccountRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
 				}
 			},
 			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return false, errors.New("database connection error")
+				m.getByIDFunc = func(ctx context.Context, id int) (operationtype.OperationType, error) {
+					return operationtype.OperationType{}, errors.New("database connection error")
 				}
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -288,7 +628,7 @@ func TestHandler_Create(t *testing.T) {
 			name: "invalid operation_type_id",
 			body: map[string]interface{}{
 				"account_id":        1,
-				"operation_type_id": 5,
+				"operation_type_id": -1,
 				"amount":            123.45,
 			},
 			setupMock:              nil,
@@ -296,6 +636,54 @@ func TestHandler_Create(t *testing.T) {
 			setupOperationTypeMock: nil,
 			expectedStatus:         http.StatusBadRequest,
 		},
+		{
+			name: "amount with more than two decimals",
+			body: map[string]interface{}{
+				"account_id":        1,
+				"operation_type_id": 4,
+				"amount":            0.291,
+			},
+			setupMock: nil,
+			setupAccountMock: func(m *mockAccountRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				}
+			},
+			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
+				m.getByIDFunc = getSeededOperationType
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "amount above the largest amount",
+			body: map[string]interface{}{
+				"account_id":        1,
+				"operation_type_id": 4,
+				"amount":            "21474836.48",
+			},
+			setupMock: nil,
+			setupAccountMock: func(m *mockAccountRepository) {
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				}
+			},
+			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
+				m.getByIDFunc = getSeededOperationType
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "amount as invalid string",
+			body: map[string]interface{}{
+				"account_id":        1,
+				"operation_type_id": 4,
+				"amount":            "12,34",
+			},
+			setupMock:              nil,
+			setupAccountMock:       nil,
+			setupOperationTypeMock: nil,
+			expectedStatus:         http.StatusBadRequest,
+		},
 		{
 			name: "missing amount",
 			body: map[string]interface{}{
@@ -312,25 +700,23 @@ func TestHandler_Create(t *testing.T) {
 			body: CreateTransactionRequest{
 				AccountId:       1,
 				OperationTypeId: 4,
-				Amount:          123.45,
+				Amount:          money.ToDecimal(12345, money.DefaultCurrency),
 			},
 			setupMock: func(m *mockRepository) {
 				m.createFunc = func(ctx context.Context, t *Transaction) error {
 					return errors.New("database error")
 				}
-				m.getTransactionsWithNegativeBalanceFunc = func(ctx context.Context, accountId int) ([]Transaction, error) {
+				m.getTransactionsWithNegativeBalanceFunc = func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
 					return []Transaction{}, nil
 				}
 			},
 			setupAccountMock: func(m *mockAccountRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				m.getByIDFunc = func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
 				}
 			},
 			setupOperationTypeMock: func(m *mockOperationTypeRepository) {
-				m.existFunc = func(ctx context.Context, id int) (bool, error) {
-					return true, nil
-				}
+				m.getByIDFunc = getSeededOperationType
 			},
 			expectedStatus: http.StatusInternalServerError,
 		},
@@ -353,7 +739,7 @@ func TestHandler_Create(t *testing.T) {
 				tt.setupOperationTypeMock(mockOperationTypeRepo)
 			}
 
-			handler := NewHandler(validator.New(), mockRepo, mockAccountRepo, mockOperationTypeRepo)
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, mockOperationTypeRepo)
 
 			var bodyBytes []byte
 			var err error
@@ -395,7 +781,7 @@ func TestHandler_Create(t *testing.T) {
 					t.Error("expected non-zero operation_type_id in response")
 				}
 
-				if response.Amount == 0 {
+				if response.Amount.Sign() == 0 {
 					t.Error("expected non-zero amount in response")
 				}
 			}
@@ -407,39 +793,45 @@ func TestHandler_Create_AmountSign(t *testing.T) {
 	tests := []struct {
 		name            string
 		operationTypeId int
-		inputAmount     float64
+		inputAmount     money.Amount
 		expectedAmount  int
 	}{
 		{
 			name:            "operation type 1 converts positive to negative",
 			operationTypeId: 1,
-			inputAmount:     50.00,
+			inputAmount:     5000,
 			expectedAmount:  -5000,
 		},
 		{
 			name:            "operation type 2 converts positive to negative",
 			operationTypeId: 2,
-			inputAmount:     100.50,
+			inputAmount:     10050,
 			expectedAmount:  -10050,
 		},
 		{
 			name:            "operation type 3 converts positive to negative",
 			operationTypeId: 3,
-			inputAmount:     75.25,
+			inputAmount:     7525,
 			expectedAmount:  -7525,
 		},
 		{
 			name:            "operation type 4 keeps positive amount",
 			operationTypeId: 4,
-			inputAmount:     200.00,
+			inputAmount:     20000,
 			expectedAmount:  20000,
 		},
 		{
 			name:            "operation type 1 with decimal amount",
 			operationTypeId: 1,
-			inputAmount:     50.99,
+			inputAmount:     5099,
 			expectedAmount:  -5099,
 		},
+		{
+			name:            "operation type 1 with amount truncated by float64",
+			operationTypeId: 1,
+			inputAmount:     29,
+			expectedAmount:  -29,
+		},
 	}
 
 	for _, tt := range tests {
@@ -453,29 +845,30 @@ func TestHandler_Create_AmountSign(t *testing.T) {
 					transaction.EventDate = time.Now()
 					return nil
 				},
-				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int) ([]Transaction, error) {
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					return []Transaction{}, nil
+				},
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
 					return []Transaction{}, nil
 				},
 			}
 
 			mockAccountRepo := &mockAccountRepository{
-				existFunc: func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
 				},
 			}
 
 			mockOperationTypeRepo := &mockOperationTypeRepository{
-				existFunc: func(ctx context.Context, id int) (bool, error) {
-					return true, nil
-				},
+				getByIDFunc: getSeededOperationType,
 			}
 
-			handler := NewHandler(validator.New(), mockRepo, mockAccountRepo, mockOperationTypeRepo)
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, mockOperationTypeRepo)
 
 			body := CreateTransactionRequest{
 				AccountId:       1,
 				OperationTypeId: tt.operationTypeId,
-				Amount:          tt.inputAmount,
+				Amount:          money.ToDecimal(tt.inputAmount, money.DefaultCurrency),
 			}
 
 			bodyBytes, err := json.Marshal(body)
@@ -503,162 +896,395 @@ func TestHandler_Create_AmountSign(t *testing.T) {
 				t.Fatalf("failed to decode response: %v", err)
 			}
 
-			if response.Amount < 0 {
-				t.Errorf("response amount should always be positive, got %f", response.Amount)
+			if response.Amount.Sign() < 0 {
+				t.Errorf("response amount should always be positive, got %s", response.Amount)
 			}
 
-			expectedResponseAmount := math.Abs(float64(tt.expectedAmount) / 100)
-			if response.Amount != expectedResponseAmount {
-				t.Errorf("expected response amount %f, got %f", expectedResponseAmount, response.Amount)
+			expectedResponseAmount := money.Amount(tt.expectedAmount).Abs()
+			if responseAmount, err := response.Amount.MinorUnits(money.DefaultCurrency); err != nil || responseAmount != expectedResponseAmount {
+				t.Errorf("expected response amount %d, got %s", expectedResponseAmount, response.Amount)
 			}
 		})
 	}
 }
 
-func TestHandler_Create_PaymentAllocation(t *testing.T) {
+func TestHandler_Create_OperationTypeMetadata(t *testing.T) {
+	openDebt := Transaction{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}, Amount: -3000, Balance: -3000}
+	unusedCredit := Transaction{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Amount: 1000, Balance: 1000}
+
 	tests := []struct {
-		name                     string
-		paymentAmount            float64
-		existingNegativeBalances []Transaction
-		expectedBalanceUpdates   map[string]int
+		name                   string
+		operationType          operationtype.OperationType
+		expectedStoredAmount   int
+		expectedStoredBalance  int
+		expectedBalanceUpdates int
 	}{
 		{
-			name:          "payment fully covers single debt",
-			paymentAmount: 100.00,
-			existingNegativeBalances: []Transaction{
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
-					Balance: -10000,
-				},
-			},
-			expectedBalanceUpdates: map[string]int{
-				"01000000-0000-0000-0000-000000000000": 0,
-			},
+			name:                   "debit type settles with unused credit",
+			operationType:          operationtype.OperationType{ID: 7, Description: "Fee", Direction: operationtype.DirectionDebit, Active: true},
+			expectedStoredAmount:   -5000,
+			expectedStoredBalance:  -4000,
+			expectedBalanceUpdates: 1,
 		},
 		{
-			name:          "payment partially covers single debt",
-			paymentAmount: 50.00,
-			existingNegativeBalances: []Transaction{
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
-					Balance: -10000,
-				},
-			},
-			expectedBalanceUpdates: map[string]int{
-				"01000000-0000-0000-0000-000000000000": -5000,
-			},
+			name:                   "discharging credit type pays off open debts",
+			operationType:          operationtype.OperationType{ID: 8, Description: "Payment", Direction: operationtype.DirectionCredit, DischargesBalance: true, Active: true},
+			expectedStoredAmount:   5000,
+			expectedStoredBalance:  2000,
+			expectedBalanceUpdates: 1,
 		},
 		{
-			name:          "payment covers multiple debts fully",
-			paymentAmount: 300.00,
-			existingNegativeBalances: []Transaction{
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
-					Balance: -10000,
-				},
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
-					Balance: -15000,
-				},
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
-					Balance: -5000,
-				},
-			},
-			expectedBalanceUpdates: map[string]int{
-				"01000000-0000-0000-0000-000000000000": 0,
-				"02000000-0000-0000-0000-000000000000": 0,
-				"03000000-0000-0000-0000-000000000000": 0,
-			},
+			name:                   "non discharging credit type is kept whole",
+			operationType:          operationtype.OperationType{ID: 9, Description: "Cashback", Direction: operationtype.DirectionCredit, Active: true},
+			expectedStoredAmount:   5000,
+			expectedStoredBalance:  5000,
+			expectedBalanceUpdates: 0,
 		},
-		{
-			name:          "payment covers some debts but not all",
-			paymentAmount: 180.00,
-			existingNegativeBalances: []Transaction{
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
-					Balance: -10000, // R$ 100,00
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored *Transaction
+			balanceUpdates := 0
+
+			mockRepo := &mockRepository{
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					stored = transaction
+					return nil
 				},
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
-					Balance: -15000, // R$ 150,00
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					return []Transaction{openDebt}, nil
 				},
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
-					Balance: -5000, // R$ 50,00
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return []Transaction{unusedCredit}, nil
 				},
-			},
-			expectedBalanceUpdates: map[string]int{
-				"01000000-0000-0000-0000-000000000000": 0,
-				"02000000-0000-0000-0000-000000000000": -7000,
-			},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					if debitId != stored.ID && creditId != stored.ID {
+						t.Errorf("expected the new transaction to be settled, got %v and %v", debitId, creditId)
+					}
+					balanceUpdates++
+					return nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+			}
+
+			mockOperationTypeRepo := &mockOperationTypeRepository{
+				getByIDFunc: func(ctx context.Context, id int) (operationtype.OperationType, error) {
+					return tt.operationType, nil
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, mockOperationTypeRepo)
+
+			bodyBytes, err := json.Marshal(CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: tt.operationType.ID,
+				Amount:          money.ToDecimal(5000, money.DefaultCurrency),
+			})
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d. Response body: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+
+			if stored.Amount != tt.expectedStoredAmount {
+				t.Errorf("expected stored amount %d, got %d", tt.expectedStoredAmount, stored.Amount)
+			}
+
+			if stored.Balance != tt.expectedStoredBalance {
+				t.Errorf("expected stored balance %d, got %d", tt.expectedStoredBalance, stored.Balance)
+			}
+
+			if balanceUpdates != tt.expectedBalanceUpdates {
+				t.Errorf("expected %d balance updates, got %d", tt.expectedBalanceUpdates, balanceUpdates)
+			}
+		})
+	}
+}
+
+func TestInstallmentDueDate(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 10, 32, 7, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name             string
+		purchase         time.Time
+		expectedDueDates []time.Time
+	}{
+		{
+			name:             "middle of the month",
+			purchase:         date(2025, time.January, 15),
+			expectedDueDates: []time.Time{date(2025, time.January, 15), date(2025, time.February, 15), date(2025, time.March, 15)},
 		},
 		{
-			name:          "payment covers first debt and part of second",
-			paymentAmount: 120.00,
-			existingNegativeBalances: []Transaction{
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
-					Balance: -10000,
+			name:             "31st through a leap February",
+			purchase:         date(2024, time.January, 31),
+			expectedDueDates: []time.Time{date(2024, time.January, 31), date(2024, time.February, 29), date(2024, time.March, 31), date(2024, time.April, 30)},
+		},
+		{
+			name:             "30th through a common February",
+			purchase:         date(2025, time.January, 30),
+			expectedDueDates: []time.Time{date(2025, time.January, 30), date(2025, time.February, 28), date(2025, time.March, 30)},
+		},
+		{
+			name:             "29th across the year end",
+			purchase:         date(2024, time.December, 29),
+			expectedDueDates: []time.Time{date(2024, time.December, 29), date(2025, time.January, 29), date(2025, time.February, 28), date(2025, time.March, 29)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, expected := range tt.expectedDueDates {
+				if dueDate := installmentDueDate(tt.purchase, i+1); !dueDate.Equal(expected) {
+					t.Errorf("installment %d: expected due date %s, got %s", i+1, expected, dueDate)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_SettleDueDebts(t *testing.T) {
+	credit := func(n byte, balance int) Transaction {
+		return Transaction{ID: pgtype.UUID{Bytes: [16]byte{n}, Valid: true}, Amount: balance, Balance: balance}
+	}
+	installment := func(n byte, balance int) Transaction {
+		return Transaction{ID: pgtype.UUID{Bytes: [16]byte{n}, Valid: true}, OperationTypeId: 2, Amount: balance, Balance: balance, InstallmentNumber: 2}
+	}
+
+	type settlement struct {
+		debit, credit byte
+		amount        int
+	}
+
+	tests := []struct {
+		name                string
+		currency            money.Currency
+		credits             []Transaction
+		debts               []Transaction
+		settleErr           error
+		expectedSettlements []settlement
+		expectedRestored    int
+		expectErr           bool
+	}{
+		{
+			name:                "credit pays part of an installment that fell due",
+			currency:            money.DefaultCurrency,
+			credits:             []Transaction{credit(1, 1000)},
+			debts:               []Transaction{installment(11, -2500)},
+			expectedSettlements: []settlement{{11, 1, 1000}},
+			expectedRestored:    1000,
+		},
+		{
+			name:                "credits spent oldest first across debts",
+			currency:            money.DefaultCurrency,
+			credits:             []Transaction{credit(1, 300), credit(2, 1000)},
+			debts:               []Transaction{installment(11, -500), installment(12, -500)},
+			expectedSettlements: []settlement{{11, 1, 300}, {11, 2, 200}, {12, 2, 500}},
+			expectedRestored:    1000,
+		},
+		{
+			name:                "credit in another currency keeps the limit",
+			currency:            "USD",
+			credits:             []Transaction{credit(1, 1000)},
+			debts:               []Transaction{installment(11, -400)},
+			expectedSettlements: []settlement{{11, 1, 400}},
+		},
+		{
+			name:     "non-discharging credit left out by the repository",
+			currency: money.DefaultCurrency,
+			debts:    []Transaction{installment(11, -400)},
+		},
+		{
+			name:      "settlement failure",
+			currency:  money.DefaultCurrency,
+			credits:   []Transaction{credit(1, 1000)},
+			debts:     []Transaction{installment(11, -400)},
+			settleErr: errors.New("database error"),
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var settlements []settlement
+			var locked []int
+
+			mockRepo := &mockRepository{
+				getAccountsWithDueDebtsAndCreditFunc: func(ctx context.Context, dueBy time.Time) ([]AccountCurrency, error) {
+					return []AccountCurrency{{AccountId: 1, Currency: tt.currency}}, nil
 				},
-				{
-					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
-					Balance: -15000,
+				lockAccountFunc: func(ctx context.Context, accountId int) error {
+					locked = append(locked, accountId)
+					return nil
 				},
-			},
-			expectedBalanceUpdates: map[string]int{
-				"01000000-0000-0000-0000-000000000000": 0,
-				"02000000-0000-0000-0000-000000000000": -13000,
-			},
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return tt.credits, nil
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					return tt.debts, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					if tt.settleErr != nil {
+						return tt.settleErr
+					}
+					settlements = append(settlements, settlement{debitId.Bytes[0], creditId.Bytes[0], amount})
+					return nil
+				},
+			}
+
+			restored := 0
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+				restoreLimitFunc: func(ctx context.Context, id int, amount int) error {
+					restored += amount
+					return nil
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, &mockOperationTypeRepository{})
+
+			err := handler.settleDueDebts(context.Background(), time.Now())
+
+			if tt.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(locked) != 1 || locked[0] != 1 {
+				t.Errorf("expected account 1 to be locked, got %v", locked)
+			}
+			if !slices.Equal(settlements, tt.expectedSettlements) {
+				t.Errorf("expected settlements %v, got %v", tt.expectedSettlements, settlements)
+			}
+			if restored != tt.expectedRestored {
+				t.Errorf("expected %d of limit restored, got %d", tt.expectedRestored, restored)
+			}
+		})
+	}
+}
+
+func TestHandler_Create_Installments(t *testing.T) {
+	unusedCredit := Transaction{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Amount: 1000, Balance: 1000}
+
+	tests := []struct {
+		name                 string
+		operationTypeId      int
+		amount               int64
+		installments         int
+		expectedStatus       int
+		expectedAmounts      []int
+		expectedBalances     []int
+		expectedInstallments int
+	}{
+		{
+			name:                 "remainder cent goes to the first installment",
+			operationTypeId:      2,
+			amount:               10000,
+			installments:         3,
+			expectedStatus:       http.StatusCreated,
+			expectedAmounts:      []int{-10000, -3334, -3333, -3333},
+			expectedBalances:     []int{0, -2334, -3333, -3333},
+			expectedInstallments: 3,
 		},
 		{
-			name:                     "payment when no debts exist",
-			paymentAmount:            100.00,
-			existingNegativeBalances: []Transaction{},
-			expectedBalanceUpdates:   map[string]int{},
+			name:                 "even split",
+			operationTypeId:      2,
+			amount:               5000,
+			installments:         2,
+			expectedStatus:       http.StatusCreated,
+			expectedAmounts:      []int{-5000, -2500, -2500},
+			expectedBalances:     []int{0, -1500, -2500},
+			expectedInstallments: 2,
+		},
+		{
+			name:             "single installment is a plain purchase",
+			operationTypeId:  2,
+			amount:           5000,
+			installments:     1,
+			expectedStatus:   http.StatusCreated,
+			expectedAmounts:  []int{-5000},
+			expectedBalances: []int{-4000},
+		},
+		{
+			name:            "operation type without installments",
+			operationTypeId: 1,
+			amount:          5000,
+			installments:    3,
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "amount smaller than the number of installments",
+			operationTypeId: 2,
+			amount:          2,
+			installments:    3,
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "too many installments",
+			operationTypeId: 2,
+			amount:          5000,
+			installments:    49,
+			expectedStatus:  http.StatusBadRequest,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			balanceUpdates := make(map[string]int)
+			var stored []*Transaction
 
 			mockRepo := &mockRepository{
 				createFunc: func(ctx context.Context, transaction *Transaction) error {
-					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
-					transaction.EventDate = time.Now()
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{byte(len(stored) + 10)}, Valid: true}
+					stored = append(stored, transaction)
 					return nil
 				},
-				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int) ([]Transaction, error) {
-					return tt.existingNegativeBalances, nil
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return []Transaction{unusedCredit}, nil
 				},
-				updateTransactionBalanceFunc: func(ctx context.Context, uuid pgtype.UUID, balance int) error {
-					balanceUpdates[uuid.String()] = balance
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
 					return nil
 				},
 			}
 
 			mockAccountRepo := &mockAccountRepository{
-				existFunc: func(ctx context.Context, id int) (bool, error) {
-					return true, nil
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
 				},
 			}
 
-			mockOperationTypeRepo := &mockOperationTypeRepository{
-				existFunc: func(ctx context.Context, id int) (bool, error) {
-					return true, nil
-				},
-			}
+			mockOperationTypeRepo := &mockOperationTypeRepository{getByIDFunc: getSeededOperationType}
 
-			handler := NewHandler(validator.New(), mockRepo, mockAccountRepo, mockOperationTypeRepo)
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, mockOperationTypeRepo)
 
-			body := CreateTransactionRequest{
+			bodyBytes, err := json.Marshal(CreateTransactionRequest{
 				AccountId:       1,
-				OperationTypeId: 4,
-				Amount:          tt.paymentAmount,
-			}
-
-			bodyBytes, err := json.Marshal(body)
+				OperationTypeId: tt.operationTypeId,
+				Amount:          money.ToDecimal(money.Amount(tt.amount), money.DefaultCurrency),
+				Installments:    tt.installments,
+			})
 			if err != nil {
 				t.Fatalf("failed to marshal request body: %v", err)
 			}
@@ -669,23 +1295,2347 @@ func TestHandler_Create_PaymentAllocation(t *testing.T) {
 
 			handler.Create(w, req)
 
-			if w.Code != http.StatusCreated {
-				t.Errorf("expected status %d, got %d. Response body: %s", http.StatusCreated, w.Code, w.Body.String())
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
 			}
 
-			if len(balanceUpdates) != len(tt.expectedBalanceUpdates) {
-				t.Errorf("expected %d balance updates, got %d", len(tt.expectedBalanceUpdates), len(balanceUpdates))
+			if tt.expectedStatus != http.StatusCreated {
+				if len(stored) != 0 {
+					t.Errorf("expected no transaction stored, got %d", len(stored))
+				}
+				return
 			}
 
-			for uuid, expectedBalance := range tt.expectedBalanceUpdates {
-				actualBalance, ok := balanceUpdates[uuid]
-				if !ok {
-					t.Errorf("expected balance update for transaction %s, but none found", uuid)
-					continue
+			if len(stored) != len(tt.expectedAmounts) {
+				t.Fatalf("expected %d stored transactions, got %d", len(tt.expectedAmounts), len(stored))
+			}
+			for i, transaction := range stored {
+				if transaction.Amount != tt.expectedAmounts[i] {
+					t.Errorf("transaction %d: expected amount %d, got %d", i, tt.expectedAmounts[i], transaction.Amount)
 				}
-				if actualBalance != expectedBalance {
-					t.Errorf("transaction %s: expected balance %d, got %d", uuid, expectedBalance, actualBalance)
+				if transaction.Balance != tt.expectedBalances[i] {
+					t.Errorf("transaction %d: expected balance %d, got %d", i, tt.expectedBalances[i], transaction.Balance)
 				}
+				if i > 0 {
+					if transaction.ParentID != stored[0].ID || transaction.InstallmentNumber != i {
+						t.Errorf("transaction %d: expected installment %d of the purchase, got %d of %v", i, i, transaction.InstallmentNumber, transaction.ParentID)
+					}
+					if dueDate := stored[0].EventDate.AddDate(0, i-1, 0); !transaction.EventDate.Equal(dueDate) {
+						t.Errorf("transaction %d: expected due date %v, got %v", i, dueDate, transaction.EventDate)
+					}
+				}
+			}
+
+			var response CreateTransactionResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(response.Installments) != tt.expectedInstallments {
+				t.Errorf("expected %d installments in response, got %d", tt.expectedInstallments, len(response.Installments))
+			}
+		})
+	}
+}
+
+func TestHandler_Create_Limit(t *testing.T) {
+	openDebt := Transaction{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}, Amount: -3000, Balance: -3000}
+	unusedCredit := Transaction{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Amount: 1000, Balance: 1000}
+
+	tests := []struct {
+		name             string
+		body             CreateTransactionRequest
+		reserveLimitErr  error
+		expectedStatus   int
+		expectedReserved int
+		expectedRestored int
+	}{
+		{
+			name:             "purchase reserves what credit does not settle",
+			body:             CreateTransactionRequest{AccountId: 1, OperationTypeId: 1, Amount: money.ToDecimal(5000, "BRL")},
+			expectedStatus:   http.StatusCreated,
+			expectedReserved: 4000,
+		},
+		{
+			name:           "purchase settled by credit reserves nothing",
+			body:           CreateTransactionRequest{AccountId: 1, OperationTypeId: 1, Amount: money.ToDecimal(500, "BRL")},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:             "installment purchase reserves every installment",
+			body:             CreateTransactionRequest{AccountId: 1, OperationTypeId: 2, Amount: money.ToDecimal(10000, "BRL"), Installments: 3},
+			expectedStatus:   http.StatusCreated,
+			expectedReserved: 9000,
+		},
+		{
+			name:            "purchase exceeding the available limit",
+			body:            CreateTransactionRequest{AccountId: 1, OperationTypeId: 3, Amount: money.ToDecimal(5000, "BRL")},
+			reserveLimitErr: account.ErrInsufficientLimit,
+			expectedStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:             "credit voucher restores what it pays off",
+			body:             CreateTransactionRequest{AccountId: 1, OperationTypeId: 4, Amount: money.ToDecimal(5000, "BRL")},
+			expectedStatus:   http.StatusCreated,
+			expectedRestored: 3000,
+		},
+		{
+			name:           "credit voucher in another currency restores nothing",
+			body:           CreateTransactionRequest{AccountId: 1, OperationTypeId: 4, Amount: money.ToDecimal(5000, "USD"), Currency: "USD"},
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserved, restored := 0, 0
+
+			mockRepo := &mockRepository{
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					return nil
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					if currency != money.DefaultCurrency {
+						return nil, nil
+					}
+					return []Transaction{openDebt}, nil
+				},
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return []Transaction{unusedCredit}, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					return nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+				reserveLimitFunc: func(ctx context.Context, id int, amount int) error {
+					if tt.reserveLimitErr != nil {
+						return tt.reserveLimitErr
+					}
+					reserved += amount
+					return nil
+				},
+				restoreLimitFunc: func(ctx context.Context, id int, amount int) error {
+					restored += amount
+					return nil
+				},
+			}
+
+			mockUoW := &mockUnitOfWork{}
+			handler := NewHandler(validator.New(), mockUoW, mockRepo, mockAccountRepo, &mockOperationTypeRepository{getByIDFunc: getSeededOperationType})
+
+			bodyBytes, err := json.Marshal(tt.body)
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusCreated {
+				var problem struct {
+					Code string `json:"code"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if problem.Code != "INSUFFICIENT_LIMIT" {
+					t.Errorf("expected code INSUFFICIENT_LIMIT, got %s", problem.Code)
+				}
+				if mockUoW.committed {
+					t.Error("expected the unit of work to be rolled back")
+				}
+				return
+			}
+
+			if reserved != tt.expectedReserved {
+				t.Errorf("expected %d of limit reserved, got %d", tt.expectedReserved, reserved)
+			}
+			if restored != tt.expectedRestored {
+				t.Errorf("expected %d of limit restored, got %d", tt.expectedRestored, restored)
+			}
+		})
+	}
+}
+
+func TestHandler_Create_AccountStatus(t *testing.T) {
+	cashback := operationtype.OperationType{ID: 9, Description: "Cashback", Direction: operationtype.DirectionCredit, Active: true}
+
+	tests := []struct {
+		name           string
+		status         account.Status
+		operationType  operationtype.OperationType
+		debt           int // due on the account
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "purchase on an active account",
+			status:         account.StatusActive,
+			operationType:  seededOperationTypes[1],
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "purchase on a blocked account",
+			status:         account.StatusBlocked,
+			operationType:  seededOperationTypes[1],
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_BLOCKED",
+		},
+		{
+			name:           "credit voucher on a blocked account",
+			status:         account.StatusBlocked,
+			operationType:  seededOperationTypes[4],
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "withdrawal on a closed account",
+			status:         account.StatusClosed,
+			operationType:  seededOperationTypes[3],
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_CLOSED",
+		},
+		{
+			name:           "credit voucher settling debt on a closed account",
+			status:         account.StatusClosed,
+			operationType:  seededOperationTypes[4],
+			debt:           5000,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "credit voucher exceeding the debt of a closed account",
+			status:         account.StatusClosed,
+			operationType:  seededOperationTypes[4],
+			debt:           3000,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_CLOSED",
+		},
+		{
+			name:           "credit voucher on a closed account without debt",
+			status:         account.StatusClosed,
+			operationType:  seededOperationTypes[4],
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_CLOSED",
+		},
+		{
+			name:           "credit not settling debt on a closed account",
+			status:         account.StatusClosed,
+			operationType:  cashback,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_CLOSED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					return nil
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					if tt.debt == 0 {
+						return nil, nil
+					}
+					return []Transaction{{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}, Amount: -tt.debt, Balance: -tt.debt}}, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					return nil
+				},
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return nil, nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency, Status: tt.status}, nil
+				},
+			}
+
+			mockOperationTypeRepo := &mockOperationTypeRepository{
+				getByIDFunc: func(ctx context.Context, id int) (operationtype.OperationType, error) {
+					return tt.operationType, nil
+				},
+			}
+
+			uow := &mockUnitOfWork{}
+			handler := NewHandler(validator.New(), uow, mockRepo, mockAccountRepo, mockOperationTypeRepo)
+
+			bodyBytes, err := json.Marshal(CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: tt.operationType.ID,
+				Amount:          money.ToDecimal(5000, money.DefaultCurrency),
+			})
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedCode != "" {
+				var problem struct {
+					Code string `json:"code"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if problem.Code != tt.expectedCode {
+					t.Errorf("expected code %s, got %s", tt.expectedCode, problem.Code)
+				}
+				if uow.committed {
+					t.Error("expected the unit of work not to be committed")
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Create_PaymentAllocation(t *testing.T) {
+	tests := []struct {
+		name                     string
+		paymentAmount            money.Amount
+		existingNegativeBalances []Transaction
+		expectedBalanceUpdates   map[string]int
+		expectedStoredBalance    int
+	}{
+		{
+			name:          "payment fully covers single debt",
+			paymentAmount: 10000,
+			existingNegativeBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: -10000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 0,
+			},
+		},
+		{
+			name:          "payment partially covers single debt",
+			paymentAmount: 5000,
+			existingNegativeBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: -10000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": -5000,
+			},
+		},
+		{
+			name:          "payment covers multiple debts fully",
+			paymentAmount: 30000,
+			existingNegativeBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: -10000,
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Balance: -15000,
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
+					Balance: -5000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 0,
+				"02000000-0000-0000-0000-000000000000": 0,
+				"03000000-0000-0000-0000-000000000000": 0,
+			},
+		},
+		{
+			name:          "payment covers some debts but not all",
+			paymentAmount: 18000,
+			existingNegativeBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: -10000, // R$ 100,00
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Balance: -15000, // R$ 150,00
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
+					Balance: -5000, // R$ 50,00
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 0,
+				"02000000-0000-0000-0000-000000000000": -7000,
+			},
+		},
+		{
+			name:          "payment covers first debt and part of second",
+			paymentAmount: 12000,
+			existingNegativeBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: -10000,
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Balance: -15000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 0,
+				"02000000-0000-0000-0000-000000000000": -13000,
+			},
+		},
+		{
+			name:          "payment exceeds all debts",
+			paymentAmount: 25000,
+			existingNegativeBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: -10000,
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Balance: -5000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 0,
+				"02000000-0000-0000-0000-000000000000": 0,
+			},
+			expectedStoredBalance: 10000,
+		},
+		{
+			name:                     "payment when no debts exist",
+			paymentAmount:            10000,
+			existingNegativeBalances: []Transaction{},
+			expectedBalanceUpdates:   map[string]int{},
+			expectedStoredBalance:    10000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balanceUpdates := make(map[string]int)
+			var stored *Transaction
+
+			mockRepo := &mockRepository{
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					stored = transaction
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					transaction.EventDate = time.Now()
+					return nil
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					return tt.existingNegativeBalances, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					if creditId != stored.ID {
+						t.Errorf("expected the payment to settle the debt, got %v", creditId)
+					}
+					balanceUpdates[debitId.String()] = balanceOf(tt.existingNegativeBalances, debitId) + amount
+					return nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+			}
+
+			mockOperationTypeRepo := &mockOperationTypeRepository{
+				getByIDFunc: getSeededOperationType,
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, mockOperationTypeRepo)
+
+			body := CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: 4,
+				Amount:          money.ToDecimal(tt.paymentAmount, money.DefaultCurrency),
+			}
+
+			bodyBytes, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("expected status %d, got %d. Response body: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+
+			if len(balanceUpdates) != len(tt.expectedBalanceUpdates) {
+				t.Errorf("expected %d balance updates, got %d", len(tt.expectedBalanceUpdates), len(balanceUpdates))
+			}
+
+			for uuid, expectedBalance := range tt.expectedBalanceUpdates {
+				actualBalance, ok := balanceUpdates[uuid]
+				if !ok {
+					t.Errorf("expected balance update for transaction %s, but none found", uuid)
+					continue
+				}
+				if actualBalance != expectedBalance {
+					t.Errorf("transaction %s: expected balance %d, got %d", uuid, expectedBalance, actualBalance)
+				}
+			}
+
+			if stored.Balance != tt.expectedStoredBalance {
+				t.Errorf("expected stored balance %d, got %d", tt.expectedStoredBalance, stored.Balance)
+			}
+		})
+	}
+}
+
+func TestHandler_Create_AllocationStrategy(t *testing.T) {
+	// Oldest first: an installment, a purchase and a withdrawal, each owing 30.00
+	debts := []Transaction{
+		{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}, OperationTypeId: 2, Balance: -3000},
+		{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, OperationTypeId: 1, Balance: -3000},
+		{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, OperationTypeId: 3, Balance: -3000},
+	}
+
+	tests := []struct {
+		name                  string
+		accountStrategy       string
+		operationTypeStrategy string
+		expectedApplied       map[byte]int
+	}{
+		{
+			name:            "oldest first by default",
+			expectedApplied: map[byte]int{1: 3000, 2: 1500},
+		},
+		{
+			name:            "strategy of the account",
+			accountStrategy: AllocationWithdrawalsFirst,
+			expectedApplied: map[byte]int{3: 3000, 1: 1500},
+		},
+		{
+			name:            "highest interest first with the rates of the operation types",
+			accountStrategy: AllocationHighestInterestFirst,
+			expectedApplied: map[byte]int{3: 3000, 2: 1500},
+		},
+		{
+			name:                  "strategy of the operation type overrides the account",
+			accountStrategy:       AllocationWithdrawalsFirst,
+			operationTypeStrategy: AllocationProportional,
+			expectedApplied:       map[byte]int{1: 1500, 2: 1500, 3: 1500},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := map[byte]int{}
+
+			mockRepo := &mockRepository{
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					return nil
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					return debts, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					applied[debitId.Bytes[0]] += amount
+					return nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency, AllocationStrategy: tt.accountStrategy}, nil
+				},
+			}
+
+			mockOperationTypeRepo := &mockOperationTypeRepository{
+				getByIDFunc: func(ctx context.Context, id int) (operationtype.OperationType, error) {
+					operationType, err := getSeededOperationType(ctx, id)
+					operationType.AllocationStrategy = tt.operationTypeStrategy
+					return operationType, err
+				},
+				listFunc: func(ctx context.Context) ([]operationtype.OperationType, error) {
+					operationTypes := make([]operationtype.OperationType, 0, len(seededOperationTypes))
+					for _, operationType := range seededOperationTypes {
+						operationTypes = append(operationTypes, operationType)
+					}
+					return operationTypes, nil
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, mockOperationTypeRepo)
+
+			bodyBytes, err := json.Marshal(CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: 4,
+				Amount:          money.ToDecimal(4500, money.DefaultCurrency),
+			})
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d. Response body: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+
+			if len(applied) != len(tt.expectedApplied) {
+				t.Errorf("expected %d debts settled, got %v", len(tt.expectedApplied), applied)
+			}
+			for n, expected := range tt.expectedApplied {
+				if applied[n] != expected {
+					t.Errorf("debt %d: expected %d applied, got %d", n, expected, applied[n])
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Create_CreditConsumption(t *testing.T) {
+	tests := []struct {
+		name                     string
+		operationTypeId          int
+		debitAmount              money.Amount
+		existingPositiveBalances []Transaction
+		expectedBalanceUpdates   map[string]int
+		expectedStoredBalance    int
+	}{
+		{
+			name:                     "debit without available credit",
+			operationTypeId:          1,
+			debitAmount:              5000,
+			existingPositiveBalances: []Transaction{},
+			expectedBalanceUpdates:   map[string]int{},
+			expectedStoredBalance:    -5000,
+		},
+		{
+			name:            "credit fully covers debit",
+			operationTypeId: 1,
+			debitAmount:     5000,
+			existingPositiveBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: 8000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 3000,
+			},
+			expectedStoredBalance: 0,
+		},
+		{
+			name:            "credit partially covers debit",
+			operationTypeId: 3,
+			debitAmount:     10000,
+			existingPositiveBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: 2000,
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Balance: 3000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 0,
+				"02000000-0000-0000-0000-000000000000": 0,
+			},
+			expectedStoredBalance: -5000,
+		},
+		{
+			name:            "debit stops consuming once settled",
+			operationTypeId: 2,
+			debitAmount:     3000,
+			existingPositiveBalances: []Transaction{
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					Balance: 2000,
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Balance: 3000,
+				},
+				{
+					ID:      pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
+					Balance: 4000,
+				},
+			},
+			expectedBalanceUpdates: map[string]int{
+				"01000000-0000-0000-0000-000000000000": 0,
+				"02000000-0000-0000-0000-000000000000": 2000,
+			},
+			expectedStoredBalance: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balanceUpdates := make(map[string]int)
+			var stored *Transaction
+
+			mockRepo := &mockRepository{
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					stored = transaction
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					transaction.EventDate = time.Now()
+					return nil
+				},
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return tt.existingPositiveBalances, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					if debitId != stored.ID {
+						t.Errorf("expected the credit to settle the debit, got %v", debitId)
+					}
+					balanceUpdates[creditId.String()] = balanceOf(tt.existingPositiveBalances, creditId) - amount
+					return nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+			}
+
+			mockOperationTypeRepo := &mockOperationTypeRepository{
+				getByIDFunc: getSeededOperationType,
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, mockOperationTypeRepo)
+
+			body := CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: tt.operationTypeId,
+				Amount:          money.ToDecimal(tt.debitAmount, money.DefaultCurrency),
+			}
+
+			bodyBytes, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("expected status %d, got %d. Response body: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+
+			if len(balanceUpdates) != len(tt.expectedBalanceUpdates) {
+				t.Errorf("expected %d balance updates, got %d", len(tt.expectedBalanceUpdates), len(balanceUpdates))
+			}
+
+			for uuid, expectedBalance := range tt.expectedBalanceUpdates {
+				actualBalance, ok := balanceUpdates[uuid]
+				if !ok {
+					t.Errorf("expected balance update for transaction %s, but none found", uuid)
+					continue
+				}
+				if actualBalance != expectedBalance {
+					t.Errorf("transaction %s: expected balance %d, got %d", uuid, expectedBalance, actualBalance)
+				}
+			}
+
+			if stored.Balance != tt.expectedStoredBalance {
+				t.Errorf("expected stored balance %d, got %d", tt.expectedStoredBalance, stored.Balance)
+			}
+		})
+	}
+}
+
+func TestHandler_Create_UnitOfWork(t *testing.T) {
+	tests := []struct {
+		name              string
+		lockErr           error
+		settleErr         error
+		expectedStatus    int
+		expectedCreate    bool
+		expectedCommitted bool
+	}{
+		{
+			name:              "insert and discharge are committed together",
+			expectedStatus:    http.StatusCreated,
+			expectedCreate:    true,
+			expectedCommitted: true,
+		},
+		{
+			name:              "account lock failure aborts the transaction",
+			lockErr:           errors.New("lock timeout"),
+			expectedStatus:    http.StatusInternalServerError,
+			expectedCreate:    false,
+			expectedCommitted: false,
+		},
+		{
+			name:              "settlement failure aborts the transaction",
+			settleErr:         errors.New("database error"),
+			expectedStatus:    http.StatusInternalServerError,
+			expectedCreate:    true,
+			expectedCommitted: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created := false
+
+			mockRepo := &mockRepository{
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					created = true
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					transaction.EventDate = time.Now()
+					return nil
+				},
+				lockAccountFunc: func(ctx context.Context, accountId int) error {
+					return tt.lockErr
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					return []Transaction{
+						{
+							ID:      pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+							Balance: -10000,
+						},
+					}, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					return tt.settleErr
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+			}
+
+			mockOperationTypeRepo := &mockOperationTypeRepository{
+				getByIDFunc: getSeededOperationType,
+			}
+
+			unitOfWork := &mockUnitOfWork{}
+			handler := NewHandler(validator.New(), unitOfWork, mockRepo, mockAccountRepo, mockOperationTypeRepo)
+
+			body := CreateTransactionRequest{
+				AccountId:       1,
+				OperationTypeId: 4,
+				Amount:          money.ToDecimal(5000, money.DefaultCurrency),
+			}
+
+			bodyBytes, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions", bytes.NewReader(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Create(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if created != tt.expectedCreate {
+				t.Errorf("expected create called to be %v, got %v", tt.expectedCreate, created)
+			}
+
+			if unitOfWork.committed != tt.expectedCommitted {
+				t.Errorf("expected committed to be %v, got %v", tt.expectedCommitted, unitOfWork.committed)
+			}
+		})
+	}
+}
+
+func TestHandler_Get(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		setupMock      func(*mockRepository)
+		expectedStatus int
+	}{
+		{
+			name: "valid request",
+			id:   "01000000-0000-0000-0000-000000000000",
+			setupMock: func(m *mockRepository) {
+				m.getByIDFunc = func(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+					return Transaction{
+						ID:                       id,
+						AccountId:                1,
+						OperationTypeId:          1,
+						OperationTypeDescription: "Normal Purchase",
+						Amount:                   -5000,
+						Balance:                  -1350,
+						Currency:                 "BRL",
+						EventDate:                time.Now(),
+					}, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid id",
+			id:             "abc",
+			setupMock:      nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "transaction not found",
+			id:   "01000000-0000-0000-0000-000000000000",
+			setupMock: func(m *mockRepository) {
+				m.getByIDFunc = func(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+					return Transaction{}, ErrNotFound
+				}
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "repository error",
+			id:   "01000000-0000-0000-0000-000000000000",
+			setupMock: func(m *mockRepository) {
+				m.getByIDFunc = func(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+					return Transaction{}, errors.New("database error")
+				}
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{}
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, &mockAccountRepository{}, &mockOperationTypeRepository{})
+
+			req := httptest.NewRequest(http.MethodGet, "/transactions/"+tt.id, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.Get(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response GetTransactionResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				if response.ID.String() != tt.id {
+					t.Errorf("expected id %s, got %s", tt.id, response.ID)
+				}
+
+				if response.OperationTypeDescription != "Normal Purchase" {
+					t.Errorf("expected operation type description, got %q", response.OperationTypeDescription)
+				}
+
+				if response.Amount != money.ToDecimal(5000, "BRL") || response.Balance != money.ToDecimal(-1350, "BRL") || response.Currency != "BRL" {
+					t.Errorf("expected amount 50.00 and balance -13.50, got %s and %s", response.Amount, response.Balance)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Get_Installments(t *testing.T) {
+	parentId := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	purchaseDate := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	mockRepo := &mockRepository{
+		getByIDFunc: func(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+			return Transaction{
+				ID:                       id,
+				AccountId:                1,
+				OperationTypeId:          2,
+				OperationTypeDescription: "Purchase with installments",
+				Amount:                   -10000,
+				Currency:                 "BRL",
+				EventDate:                purchaseDate,
+				Installments:             2,
+			}, nil
+		},
+		getInstallmentsFunc: func(ctx context.Context, id pgtype.UUID) ([]Transaction, error) {
+			if id != parentId {
+				t.Errorf("expected installments of %v, got %v", parentId, id)
+			}
+			return []Transaction{
+				{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Amount: -5000, Balance: 0, Currency: "BRL", EventDate: purchaseDate, ParentID: parentId, Installments: 2, InstallmentNumber: 1},
+				{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Amount: -5000, Balance: -5000, Currency: "BRL", EventDate: purchaseDate.AddDate(0, 1, 0), ParentID: parentId, Installments: 2, InstallmentNumber: 2},
+			}, nil
+		},
+	}
+
+	handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, &mockAccountRepository{}, &mockOperationTypeRepository{})
+
+	id := parentId.String()
+	req := httptest.NewRequest(http.MethodGet, "/transactions/"+id, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+
+	handler.Get(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d. Response body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response GetTransactionResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(response.Installments) != 2 {
+		t.Fatalf("expected 2 installments, got %d", len(response.Installments))
+	}
+	second := response.Installments[1]
+	if second.InstallmentNumber != 2 || second.Amount != money.ToDecimal(5000, "BRL") || second.DueDate != "2026-02-15T10:00:00Z" {
+		t.Errorf("unexpected second installment %+v", second)
+	}
+}
+
+func TestHandler_Settlements(t *testing.T) {
+	paymentId := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	payment := Transaction{ID: paymentId, AccountId: 1, OperationTypeId: 4, Amount: 6000, Balance: 0, Currency: money.DefaultCurrency}
+	settledAt := time.Date(2020, 1, 1, 10, 32, 10, 0, time.UTC)
+	settlements := []Settlement{
+		{ID: pgtype.UUID{Bytes: [16]byte{11}, Valid: true}, PaymentID: paymentId, DebitID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Amount: 5000, CreatedAt: settledAt},
+		{ID: pgtype.UUID{Bytes: [16]byte{12}, Valid: true}, PaymentID: paymentId, DebitID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Amount: 1000, CreatedAt: settledAt},
+	}
+
+	tests := []struct {
+		name              string
+		id                string
+		getByIDErr        error
+		settlements       []Settlement
+		getSettlementsErr error
+		expectedStatus    int
+		expectedCode      string
+		expectedAmounts   []string
+	}{
+		{
+			name:            "payment that settled two purchases",
+			id:              "01000000-0000-0000-0000-000000000000",
+			settlements:     settlements,
+			expectedStatus:  http.StatusOK,
+			expectedAmounts: []string{"50.00", "10.00"},
+		},
+		{
+			name:            "transaction without settlements",
+			id:              "01000000-0000-0000-0000-000000000000",
+			settlements:     []Settlement{},
+			expectedStatus:  http.StatusOK,
+			expectedAmounts: []string{},
+		},
+		{
+			name:           "transaction not found",
+			id:             "01000000-0000-0000-0000-000000000000",
+			getByIDErr:     ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "TRANSACTION_NOT_FOUND",
+		},
+		{
+			name:              "repository error",
+			id:                "01000000-0000-0000-0000-000000000000",
+			getSettlementsErr: errors.New("database error"),
+			expectedStatus:    http.StatusInternalServerError,
+		},
+		{
+			name:           "invalid id",
+			id:             "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getByIDFunc: func(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+					return payment, tt.getByIDErr
+				},
+				getSettlementsFunc: func(ctx context.Context, transactionId pgtype.UUID) ([]Settlement, error) {
+					if transactionId != paymentId {
+						t.Errorf("expected settlements of %v, got %v", paymentId, transactionId)
+					}
+					return tt.settlements, tt.getSettlementsErr
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, &mockAccountRepository{}, &mockOperationTypeRepository{})
+
+			req := httptest.NewRequest(http.MethodGet, "/transactions/"+tt.id+"/settlements", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.Settlements(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				if tt.expectedCode != "" {
+					var problem struct {
+						Code string `json:"code"`
+					}
+					if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+						t.Fatalf("failed to decode response: %v", err)
+					}
+					if problem.Code != tt.expectedCode {
+						t.Errorf("expected code %s, got %s", tt.expectedCode, problem.Code)
+					}
+				}
+				return
+			}
+
+			var response ListSettlementsResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.TransactionID.String() != tt.id {
+				t.Errorf("expected transaction id %s, got %s", tt.id, response.TransactionID)
+			}
+			if response.Data == nil || len(response.Data) != len(tt.expectedAmounts) {
+				t.Fatalf("expected %d settlements, got %v", len(tt.expectedAmounts), response.Data)
+			}
+			for i, item := range response.Data {
+				if item.Amount.String() != tt.expectedAmounts[i] || item.Currency != "BRL" {
+					t.Errorf("settlement %d: expected %s BRL, got %s %s", i, tt.expectedAmounts[i], item.Amount, item.Currency)
+				}
+				if item.PaymentID.String() != tt.id {
+					t.Errorf("settlement %d: expected payment %s, got %s", i, tt.id, item.PaymentID)
+				}
+				if item.SettledAt != "2020-01-01T10:32:10Z" {
+					t.Errorf("settlement %d: expected settled_at 2020-01-01T10:32:10Z, got %s", i, item.SettledAt)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Reverse(t *testing.T) {
+	originalId := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	openDebt := Transaction{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Amount: -1000, Balance: -1000}
+	unusedCredit := Transaction{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Amount: 500, Balance: 500}
+
+	purchase := Transaction{ID: originalId, AccountId: 1, OperationTypeId: 1, Amount: -5000, Balance: -2000, Currency: "BRL", Installments: 1}
+	voucher := Transaction{ID: originalId, AccountId: 1, OperationTypeId: 4, Amount: 5000, Balance: 1000, Currency: "BRL", Installments: 1}
+	reversal := purchase
+	reversal.ReversalOf = pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	installmentPurchase := purchase
+	installmentPurchase.Installments = 3
+	transferDebit := purchase
+	transferDebit.OperationTypeId = 5
+	transferDebit.TransferID = pgtype.UUID{Bytes: [16]byte{8}, Valid: true}
+	transferCredit := voucher
+	transferCredit.TransferID = transferDebit.TransferID
+
+	tests := []struct {
+		name                    string
+		id                      string
+		body                    string
+		original                Transaction
+		reversed                int
+		status                  account.Status
+		getByIDErr              error
+		expectedStatus          int
+		expectedCode            string
+		expectedStoredAmount    int
+		expectedStoredBalance   int
+		expectedOriginalBalance int
+		expectedReservedLimit   int
+	}{
+		{
+			name:                    "full reversal of a partially paid purchase",
+			id:                      originalId.String(),
+			original:                purchase,
+			expectedStatus:          http.StatusCreated,
+			expectedStoredAmount:    5000,
+			expectedStoredBalance:   2000,
+			expectedOriginalBalance: 0,
+			expectedReservedLimit:   -3000,
+		},
+		{
+			name:                    "partial reversal of a purchase",
+			id:                      originalId.String(),
+			body:                    `{"amount": 10.00}`,
+			original:                purchase,
+			expectedStatus:          http.StatusCreated,
+			expectedStoredAmount:    1000,
+			expectedStoredBalance:   0,
+			expectedOriginalBalance: -1000,
+			expectedReservedLimit:   -1000,
+		},
+		{
+			name:                    "full reversal of a partially used credit voucher",
+			id:                      originalId.String(),
+			body:                    `{}`,
+			original:                voucher,
+			expectedStatus:          http.StatusCreated,
+			expectedStoredAmount:    -5000,
+			expectedStoredBalance:   -3500,
+			expectedOriginalBalance: 0,
+			expectedReservedLimit:   3500,
+		},
+		{
+			name:                    "partial reversal paying off a purchase of a closed account",
+			id:                      originalId.String(),
+			body:                    `{"amount": 10.00}`,
+			original:                purchase,
+			status:                  account.StatusClosed,
+			expectedStatus:          http.StatusCreated,
+			expectedStoredAmount:    1000,
+			expectedStoredBalance:   0,
+			expectedOriginalBalance: -1000,
+			expectedReservedLimit:   -1000,
+		},
+		{
+			name:           "reversal exceeding the debt of a closed account",
+			id:             originalId.String(),
+			original:       purchase,
+			status:         account.StatusClosed,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_CLOSED",
+		},
+		{
+			name:           "reversal exceeding what is left",
+			id:             originalId.String(),
+			body:           `{"amount": 20.00}`,
+			original:       purchase,
+			reversed:       4000,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "REVERSAL_EXCEEDS_AMOUNT",
+		},
+		{
+			name:           "transaction already fully reversed",
+			id:             originalId.String(),
+			original:       purchase,
+			reversed:       5000,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "REVERSAL_EXCEEDS_AMOUNT",
+		},
+		{
+			name:           "reversal of a reversal",
+			id:             originalId.String(),
+			original:       reversal,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "REVERSAL_NOT_ALLOWED",
+		},
+		{
+			name:           "installment purchase",
+			id:             originalId.String(),
+			original:       installmentPurchase,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "REVERSAL_NOT_ALLOWED",
+		},
+		{
+			name:           "debit of a transfer",
+			id:             originalId.String(),
+			original:       transferDebit,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "REVERSAL_NOT_ALLOWED",
+		},
+		{
+			name:           "credit of a transfer",
+			id:             originalId.String(),
+			original:       transferCredit,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "REVERSAL_NOT_ALLOWED",
+		},
+		{
+			name:           "transaction not found",
+			id:             originalId.String(),
+			getByIDErr:     ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "TRANSACTION_NOT_FOUND",
+		},
+		{
+			name:           "invalid id",
+			id:             "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative amount",
+			id:             originalId.String(),
+			body:           `{"amount": -10.00}`,
+			original:       purchase,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "too many decimal places",
+			id:             originalId.String(),
+			body:           `{"amount": 10.001}`,
+			original:       purchase,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored *Transaction
+			balanceUpdates := map[pgtype.UUID]int{}
+
+			mockRepo := &mockRepository{
+				getByIDFunc: func(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+					return tt.original, tt.getByIDErr
+				},
+				sumReversalsFunc: func(ctx context.Context, id pgtype.UUID) (int, error) {
+					return tt.reversed, nil
+				},
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{99}, Valid: true}
+					stored = transaction
+					return nil
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					return []Transaction{openDebt}, nil
+				},
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return []Transaction{unusedCredit}, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					existing := []Transaction{tt.original, openDebt, unusedCredit}
+					switch stored.ID {
+					case creditId:
+						balanceUpdates[debitId] = balanceOf(existing, debitId) + amount
+					case debitId:
+						balanceUpdates[creditId] = balanceOf(existing, creditId) - amount
+					default:
+						t.Errorf("expected the reversal to be settled, got %v and %v", debitId, creditId)
+					}
+					return nil
+				},
+			}
+
+			reservedLimit := 0
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency, Status: tt.status}, nil
+				},
+				reserveLimitFunc: func(ctx context.Context, id int, amount int) error {
+					reservedLimit += amount
+					return nil
+				},
+				restoreLimitFunc: func(ctx context.Context, id int, amount int) error {
+					reservedLimit -= amount
+					return nil
+				},
+			}
+
+			uow := &mockUnitOfWork{}
+			handler := NewHandler(validator.New(), uow, mockRepo, mockAccountRepo, &mockOperationTypeRepository{})
+
+			req := httptest.NewRequest(http.MethodPost, "/transactions/"+tt.id+"/reversal", bytes.NewReader([]byte(tt.body)))
+			req.Header.Set("Content-Type", "application/json")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.Reverse(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusCreated {
+				if tt.expectedCode != "" {
+					var problem struct {
+						Code string `json:"code"`
+					}
+					if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+						t.Fatalf("failed to decode response: %v", err)
+					}
+					if problem.Code != tt.expectedCode {
+						t.Errorf("expected code %s, got %s", tt.expectedCode, problem.Code)
+					}
+				}
+				if uow.committed {
+					t.Error("expected the unit of work not to be committed")
+				}
+				return
+			}
+
+			if stored == nil {
+				t.Fatal("expected a reversal to be stored")
+			}
+			if stored.ReversalOf != originalId {
+				t.Errorf("expected reversal of %v, got %v", originalId, stored.ReversalOf)
+			}
+			if stored.Amount != tt.expectedStoredAmount {
+				t.Errorf("expected stored amount %d, got %d", tt.expectedStoredAmount, stored.Amount)
+			}
+			if stored.Balance != tt.expectedStoredBalance {
+				t.Errorf("expected stored balance %d, got %d", tt.expectedStoredBalance, stored.Balance)
+			}
+			if balance, ok := balanceUpdates[originalId]; !ok || balance != tt.expectedOriginalBalance {
+				t.Errorf("expected original balance %d, got %d (updated: %v)", tt.expectedOriginalBalance, balance, ok)
+			}
+			if reservedLimit != tt.expectedReservedLimit {
+				t.Errorf("expected %d of limit reserved, got %d", tt.expectedReservedLimit, reservedLimit)
+			}
+
+			var response TransactionResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.ReversalOf == nil || response.ReversalOf.String() != originalId.String() {
+				t.Errorf("expected reversal_of %s, got %v", originalId, response.ReversalOf)
+			}
+		})
+	}
+}
+
+func TestHandler_Transfer(t *testing.T) {
+	transferId := pgtype.UUID{Bytes: [16]byte{7}, Valid: true}
+	// The destination account owes 30.00 from a past purchase
+	openDebt := Transaction{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, AccountId: 2, Amount: -3000, Balance: -3000, Currency: money.DefaultCurrency}
+
+	tests := []struct {
+		name                       string
+		body                       string
+		accounts                   map[int]account.Account
+		reserveErr                 error
+		inactiveOperationType      int
+		expectedStatus             int
+		expectedCode               string
+		expectedDebitBalance       int
+		expectedCreditBalance      int
+		expectedDestinationBalance int
+		expectedLimitChanges       map[int]int
+	}{
+		{
+			name: "credit voucher discharges the debts of the destination",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: money.DefaultCurrency},
+			},
+			expectedStatus:             http.StatusCreated,
+			expectedDebitBalance:       -5000,
+			expectedCreditBalance:      2000,
+			expectedDestinationBalance: 0,
+			expectedLimitChanges:       map[int]int{1: -5000, 2: 3000},
+		},
+		{
+			name: "credit voucher partially discharges the debts of the destination",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 10.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: money.DefaultCurrency},
+			},
+			expectedStatus:             http.StatusCreated,
+			expectedDebitBalance:       -1000,
+			expectedCreditBalance:      0,
+			expectedDestinationBalance: -2000,
+			expectedLimitChanges:       map[int]int{1: -1000, 2: 1000},
+		},
+		{
+			name:           "same source and destination",
+			body:           `{"source_account_id": 1, "destination_account_id": 1, "amount": 50.00}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing amount",
+			body:           `{"source_account_id": 1, "destination_account_id": 2}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "unknown destination account",
+			body: `{"source_account_id": 1, "destination_account_id": 3, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "ACCOUNT_NOT_FOUND",
+		},
+		{
+			name: "accounts in different currencies",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: "USD"},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "CURRENCY_MISMATCH",
+		},
+		{
+			name: "source account without enough limit",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: money.DefaultCurrency},
+			},
+			reserveErr:     account.ErrInsufficientLimit,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "INSUFFICIENT_LIMIT",
+		},
+		{
+			name: "blocked source account",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency, Status: account.StatusBlocked},
+				2: {ID: 2, Currency: money.DefaultCurrency},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_BLOCKED",
+		},
+		{
+			name: "closed destination account paid off",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 30.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: money.DefaultCurrency, Status: account.StatusClosed},
+			},
+			expectedStatus:             http.StatusCreated,
+			expectedDebitBalance:       -3000,
+			expectedCreditBalance:      0,
+			expectedDestinationBalance: 0,
+			expectedLimitChanges:       map[int]int{1: -3000, 2: 3000},
+		},
+		{
+			name: "transfer exceeding the debt of a closed destination account",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: money.DefaultCurrency, Status: account.StatusClosed},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "ACCOUNT_CLOSED",
+		},
+		{
+			name: "no active debit operation type of the transfer kind",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: money.DefaultCurrency},
+			},
+			inactiveOperationType: 5,
+			expectedStatus:        http.StatusUnprocessableEntity,
+			expectedCode:          "OPERATION_TYPE_UNAVAILABLE",
+		},
+		{
+			name: "no active credit operation type of the transfer kind",
+			body: `{"source_account_id": 1, "destination_account_id": 2, "amount": 50.00}`,
+			accounts: map[int]account.Account{
+				1: {ID: 1, Currency: money.DefaultCurrency},
+				2: {ID: 2, Currency: money.DefaultCurrency},
+			},
+			inactiveOperationType: 6,
+			expectedStatus:        http.StatusUnprocessableEntity,
+			expectedCode:          "OPERATION_TYPE_UNAVAILABLE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored []*Transaction
+			var locked []int
+			balanceUpdates := map[pgtype.UUID]int{}
+
+			mockRepo := &mockRepository{
+				lockAccountFunc: func(ctx context.Context, accountId int) error {
+					locked = append(locked, accountId)
+					return nil
+				},
+				createTransferFunc: func(ctx context.Context, transfer *Transfer) error {
+					transfer.ID = transferId
+					return nil
+				},
+				createFunc: func(ctx context.Context, transaction *Transaction) error {
+					transaction.ID = pgtype.UUID{Bytes: [16]byte{byte(len(stored) + 10)}, Valid: true}
+					stored = append(stored, transaction)
+					return nil
+				},
+				getTransactionsWithPositiveBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
+					return []Transaction{}, nil
+				},
+				getTransactionsWithNegativeBalanceFunc: func(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+					if accountId == openDebt.AccountId {
+						return []Transaction{openDebt}, nil
+					}
+					return []Transaction{}, nil
+				},
+				settleFunc: func(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+					if debitId != openDebt.ID || creditId != stored[len(stored)-1].ID {
+						t.Errorf("expected the credit voucher to settle the destination debt, got %v and %v", debitId, creditId)
+					}
+					balanceUpdates[debitId] = openDebt.Balance + amount
+					return nil
+				},
+			}
+
+			limitChanges := map[int]int{}
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					if acc, ok := tt.accounts[id]; ok {
+						return acc, nil
+					}
+					return account.Account{}, account.ErrNotFound
+				},
+				reserveLimitFunc: func(ctx context.Context, id int, amount int) error {
+					if tt.reserveErr != nil {
+						return tt.reserveErr
+					}
+					limitChanges[id] -= amount
+					return nil
+				},
+				restoreLimitFunc: func(ctx context.Context, id int, amount int) error {
+					limitChanges[id] += amount
+					return nil
+				},
+			}
+
+			mockOperationTypeRepo := &mockOperationTypeRepository{
+				getByKindFunc: func(ctx context.Context, kind operationtype.Kind, direction operationtype.Direction) (operationtype.OperationType, error) {
+					operationType, err := getSeededOperationTypeByKind(ctx, kind, direction)
+					if operationType.ID == tt.inactiveOperationType {
+						return operationtype.OperationType{}, operationtype.ErrNotFound
+					}
+					return operationType, err
+				},
+			}
+
+			uow := &mockUnitOfWork{}
+			handler := NewHandler(validator.New(), uow, mockRepo, mockAccountRepo, mockOperationTypeRepo)
+
+			req := httptest.NewRequest(http.MethodPost, "/transfers", bytes.NewReader([]byte(tt.body)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handler.Transfer(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusCreated {
+				if tt.expectedCode != "" {
+					var problem struct {
+						Code string `json:"code"`
+					}
+					if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+						t.Fatalf("failed to decode response: %v", err)
+					}
+					if problem.Code != tt.expectedCode {
+						t.Errorf("expected code %s, got %s", tt.expectedCode, problem.Code)
+					}
+				}
+				if uow.committed {
+					t.Error("expected the unit of work not to be committed")
+				}
+				return
+			}
+
+			if !uow.committed {
+				t.Error("expected the unit of work to be committed")
+			}
+			if len(locked) != 2 || locked[0] != 1 || locked[1] != 2 {
+				t.Errorf("expected accounts 1 and 2 to be locked in order, got %v", locked)
+			}
+			if len(stored) != 2 {
+				t.Fatalf("expected 2 transactions to be stored, got %d", len(stored))
+			}
+
+			debit, credit := stored[0], stored[1]
+			if debit.EventDate.IsZero() || !debit.EventDate.Equal(credit.EventDate) || debit.EventDate.After(time.Now()) {
+				t.Errorf("expected both legs due now, got %v and %v", debit.EventDate, credit.EventDate)
+			}
+			if debit.AccountId != 1 || debit.OperationTypeId != 5 || debit.Amount >= 0 {
+				t.Errorf("expected a debit of operation type 5 on account 1, got %+v", debit)
+			}
+			if credit.AccountId != 2 || credit.OperationTypeId != 6 || credit.Amount <= 0 {
+				t.Errorf("expected a credit of operation type 6 on account 2, got %+v", credit)
+			}
+			if debit.TransferID != transferId || credit.TransferID != transferId {
+				t.Errorf("expected both transactions to reference transfer %v, got %v and %v", transferId, debit.TransferID, credit.TransferID)
+			}
+			if debit.Balance != tt.expectedDebitBalance {
+				t.Errorf("expected debit balance %d, got %d", tt.expectedDebitBalance, debit.Balance)
+			}
+			if credit.Balance != tt.expectedCreditBalance {
+				t.Errorf("expected credit balance %d, got %d", tt.expectedCreditBalance, credit.Balance)
+			}
+			if balance := balanceUpdates[openDebt.ID]; balance != tt.expectedDestinationBalance {
+				t.Errorf("expected destination debt balance %d, got %d", tt.expectedDestinationBalance, balance)
+			}
+			for id, expected := range tt.expectedLimitChanges {
+				if limitChanges[id] != expected {
+					t.Errorf("expected limit of account %d to change by %d, got %d", id, expected, limitChanges[id])
+				}
+			}
+
+			var response TransferResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.ID.String() != uuid.UUID(transferId.Bytes).String() {
+				t.Errorf("expected transfer id %s, got %s", uuid.UUID(transferId.Bytes), response.ID)
+			}
+			if len(response.Transactions) != 2 {
+				t.Fatalf("expected 2 transactions in the response, got %d", len(response.Transactions))
+			}
+			for _, item := range response.Transactions {
+				if item.TransferID == nil || *item.TransferID != response.ID {
+					t.Errorf("expected transaction %s to reference the transfer, got %v", item.ID, item.TransferID)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_GetTransfer(t *testing.T) {
+	transferId := uuid.MustParse("019a096b-ad9f-7f0e-88a4-9c93a754b029")
+	transfer := Transfer{
+		ID:                   pgtype.UUID{Bytes: transferId, Valid: true},
+		SourceAccountId:      1,
+		DestinationAccountId: 2,
+		Amount:               5000,
+		Currency:             money.DefaultCurrency,
+		CreatedAt:            time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+	}
+	legs := []Transaction{
+		{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}, AccountId: 1, OperationTypeId: 5, Amount: -5000, Balance: -5000, Currency: money.DefaultCurrency, TransferID: transfer.ID},
+		{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, AccountId: 2, OperationTypeId: 6, Amount: 5000, Balance: 5000, Currency: money.DefaultCurrency, TransferID: transfer.ID},
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		getTransferErr error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "existing transfer",
+			id:             transferId.String(),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "transfer not found",
+			id:             transferId.String(),
+			getTransferErr: ErrTransferNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "TRANSFER_NOT_FOUND",
+		},
+		{
+			name:           "repository error",
+			id:             transferId.String(),
+			getTransferErr: errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "invalid id",
+			id:             "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getTransferFunc: func(ctx context.Context, id pgtype.UUID) (Transfer, error) {
+					return transfer, tt.getTransferErr
+				},
+				getByTransferFunc: func(ctx context.Context, transferId pgtype.UUID) ([]Transaction, error) {
+					return legs, nil
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, &mockAccountRepository{}, &mockOperationTypeRepository{})
+
+			req := httptest.NewRequest(http.MethodGet, "/transfers/"+tt.id, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.GetTransfer(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				if tt.expectedCode != "" {
+					var problem struct {
+						Code string `json:"code"`
+					}
+					if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+						t.Fatalf("failed to decode response: %v", err)
+					}
+					if problem.Code != tt.expectedCode {
+						t.Errorf("expected code %s, got %s", tt.expectedCode, problem.Code)
+					}
+				}
+				return
+			}
+
+			var response TransferResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.ID != transferId {
+				t.Errorf("expected transfer id %s, got %s", transferId, response.ID)
+			}
+			if response.Amount.String() != "50.00" {
+				t.Errorf("expected amount 50.00, got %s", response.Amount)
+			}
+			if len(response.Transactions) != 2 || response.Transactions[0].AccountId != 1 || response.Transactions[1].AccountId != 2 {
+				t.Errorf("expected the debit on account 1 then the credit on account 2, got %+v", response.Transactions)
+			}
+		})
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	transactions := []Transaction{
+		{
+			ID:              pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+			AccountId:       1,
+			OperationTypeId: 1,
+			Amount:          -5000,
+			Balance:         -5000,
+			Currency:        "BRL",
+			EventDate:       time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:              pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+			AccountId:       1,
+			OperationTypeId: 4,
+			Amount:          2000,
+			Balance:         0,
+			Currency:        "BRL",
+			EventDate:       time.Date(2025, 10, 2, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:              pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
+			AccountId:       1,
+			OperationTypeId: 3,
+			Amount:          -1000,
+			Balance:         -1000,
+			Currency:        "BRL",
+			EventDate:       time.Date(2025, 10, 3, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	tests := []struct {
+		name               string
+		accountId          string
+		query              string
+		accountExists      bool
+		expectedStatus     int
+		expectedFilter     ListFilter
+		expectedMinAmount  int
+		expectedCount      int
+		expectedNextCursor string
+	}{
+		{
+			name:               "first page with next cursor",
+			accountId:          "1",
+			query:              "?limit=2",
+			accountExists:      true,
+			expectedStatus:     http.StatusOK,
+			expectedFilter:     ListFilter{AccountId: 1, Limit: 3},
+			expectedCount:      2,
+			expectedNextCursor: "02000000-0000-0000-0000-000000000000",
+		},
+		{
+			name:           "last page without next cursor",
+			accountId:      "1",
+			query:          "?cursor=02000000-0000-0000-0000-000000000000&operation_type_id=3",
+			accountExists:  true,
+			expectedStatus: http.StatusOK,
+			expectedFilter: ListFilter{
+				AccountId:       1,
+				OperationTypeId: 3,
+				Cursor:          pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+				Limit:           defaultListLimit + 1,
+			},
+			expectedCount: 3,
+		},
+		{
+			name:           "invalid account id",
+			accountId:      "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid cursor",
+			accountId:      "1",
+			query:          "?cursor=abc",
+			accountExists:  true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "limit above maximum",
+			accountId:      "1",
+			query:          "?limit=101",
+			accountExists:  true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid date range",
+			accountId:      "1",
+			query:          "?from=yesterday",
+			accountExists:  true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "currency filter",
+			accountId:      "1",
+			query:          "?currency=JPY",
+			accountExists:  true,
+			expectedStatus: http.StatusOK,
+			expectedFilter: ListFilter{
+				AccountId: 1,
+				Currency:  "JPY",
+				Limit:     defaultListLimit + 1,
+			},
+			expectedCount: 3,
+		},
+		{
+			name:           "amount range in the account currency",
+			accountId:      "1",
+			query:          "?min_amount=10.00",
+			accountExists:  true,
+			expectedStatus: http.StatusOK,
+			expectedFilter: ListFilter{
+				AccountId: 1,
+				Currency:  money.DefaultCurrency,
+				Limit:     defaultListLimit + 1,
+			},
+			expectedMinAmount: 1000,
+			expectedCount:     3,
+		},
+		{
+			name:           "amount range in another currency",
+			accountId:      "1",
+			query:          "?currency=JPY&min_amount=1000",
+			accountExists:  true,
+			expectedStatus: http.StatusOK,
+			expectedFilter: ListFilter{
+				AccountId: 1,
+				Currency:  "JPY",
+				Limit:     defaultListLimit + 1,
+			},
+			expectedMinAmount: 1000,
+			expectedCount:     3,
+		},
+		{
+			name:           "invalid amount range",
+			accountId:      "1",
+			query:          "?min_amount=-1",
+			accountExists:  true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "amount range with more decimals than the currency",
+			accountId:      "1",
+			query:          "?currency=JPY&min_amount=0.5",
+			accountExists:  true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported currency",
+			accountId:      "1",
+			query:          "?currency=XXX",
+			accountExists:  true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "account does not exist",
+			accountId:      "999",
+			accountExists:  false,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var capturedFilter ListFilter
+
+			mockRepo := &mockRepository{
+				listFunc: func(ctx context.Context, filter ListFilter) ([]Transaction, error) {
+					capturedFilter = filter
+					return transactions, nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					if !tt.accountExists {
+						return account.Account{}, account.ErrNotFound
+					}
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, &mockOperationTypeRepository{})
+
+			req := httptest.NewRequest(http.MethodGet, "/accounts/"+tt.accountId+"/transactions"+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("accountId", tt.accountId)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.List(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if tt.expectedMinAmount != 0 {
+				if capturedFilter.MinAmount == nil || *capturedFilter.MinAmount != tt.expectedMinAmount {
+					t.Errorf("expected minimum amount %d, got %v", tt.expectedMinAmount, capturedFilter.MinAmount)
+				}
+				capturedFilter.MinAmount = nil
+			}
+			if capturedFilter != tt.expectedFilter {
+				t.Errorf("expected filter %+v, got %+v", tt.expectedFilter, capturedFilter)
+			}
+
+			var response ListTransactionsResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if len(response.Data) != tt.expectedCount {
+				t.Errorf("expected %d transactions, got %d", tt.expectedCount, len(response.Data))
+			}
+
+			if response.NextCursor != tt.expectedNextCursor {
+				t.Errorf("expected next cursor %q, got %q", tt.expectedNextCursor, response.NextCursor)
+			}
+
+			if response.Data[0].Amount != money.ToDecimal(5000, "BRL") || response.Data[0].Balance != money.ToDecimal(-5000, "BRL") {
+				t.Errorf("expected amount 50.00 and balance -50.00, got %s and %s", response.Data[0].Amount, response.Data[0].Balance)
+			}
+		})
+	}
+}
+
+func TestHandler_Balance(t *testing.T) {
+	tests := []struct {
+		name           string
+		accountId      string
+		setupMock      func(*mockRepository)
+		accountExists  bool
+		expectedStatus int
+		expectedBody   BalanceResponse
+	}{
+		{
+			name:      "valid request",
+			accountId: "1",
+			setupMock: func(m *mockRepository) {
+				m.getBalanceFunc = func(ctx context.Context, accountId int, asOf time.Time) ([]Balance, error) {
+					return []Balance{
+						{
+							Currency:        "BRL",
+							OutstandingDebt: 3220,
+							ScheduledDebt:   4000,
+							AvailableCredit: 0,
+							Totals: []OperationTypeTotal{
+								{OperationTypeId: 1, Count: 3, Amount: 9220},
+								{OperationTypeId: 4, Count: 1, Amount: 6000},
+							},
+						},
+					}, nil
+				}
+			},
+			accountExists:  true,
+			expectedStatus: http.StatusOK,
+			expectedBody: BalanceResponse{
+				AccountId: 1,
+				Balances: []CurrencyBalanceResponse{
+					{
+						Currency:        "BRL",
+						OutstandingDebt: money.ToDecimal(3220, "BRL"),
+						ScheduledDebt:   money.ToDecimal(4000, "BRL"),
+						AvailableCredit: money.ToDecimal(0, "BRL"),
+						Totals: []OperationTypeTotalResponse{
+							{OperationTypeId: 1, Count: 3, Amount: money.ToDecimal(9220, "BRL")},
+							{OperationTypeId: 4, Count: 1, Amount: money.ToDecimal(6000, "BRL")},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "balance per currency",
+			accountId: "1",
+			setupMock: func(m *mockRepository) {
+				m.getBalanceFunc = func(ctx context.Context, accountId int, asOf time.Time) ([]Balance, error) {
+					return []Balance{
+						{Currency: "BRL", OutstandingDebt: 3220},
+						{Currency: "JPY", AvailableCredit: 1500},
+					}, nil
+				}
+			},
+			accountExists:  true,
+			expectedStatus: http.StatusOK,
+			expectedBody: BalanceResponse{
+				AccountId: 1,
+				Balances: []CurrencyBalanceResponse{
+					{
+						Currency:        "BRL",
+						OutstandingDebt: money.ToDecimal(3220, "BRL"),
+						ScheduledDebt:   money.ToDecimal(0, "BRL"),
+						AvailableCredit: money.ToDecimal(0, "BRL"),
+						Totals:          []OperationTypeTotalResponse{},
+					},
+					{
+						Currency:        "JPY",
+						OutstandingDebt: money.ToDecimal(0, "JPY"),
+						ScheduledDebt:   money.ToDecimal(0, "JPY"),
+						AvailableCredit: money.ToDecimal(1500, "JPY"),
+						Totals:          []OperationTypeTotalResponse{},
+					},
+				},
+			},
+		},
+		{
+			name:           "invalid account id",
+			accountId:      "abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "account does not exist",
+			accountId:      "999",
+			accountExists:  false,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:      "repository error",
+			accountId: "1",
+			setupMock: func(m *mockRepository) {
+				m.getBalanceFunc = func(ctx context.Context, accountId int, asOf time.Time) ([]Balance, error) {
+					return nil, errors.New("database error")
+				}
+			},
+			accountExists:  true,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{}
+			if tt.setupMock != nil {
+				tt.setupMock(mockRepo)
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				existFunc: func(ctx context.Context, id int) (bool, error) {
+					return tt.accountExists, nil
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, &mockOperationTypeRepository{})
+
+			req := httptest.NewRequest(http.MethodGet, "/accounts/"+tt.accountId+"/balance", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("accountId", tt.accountId)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.Balance(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response BalanceResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			expected, _ := json.Marshal(tt.expectedBody)
+			actual, _ := json.Marshal(response)
+			if string(expected) != string(actual) {
+				t.Errorf("expected response %s, got %s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestHandler_Statement(t *testing.T) {
+	tests := []struct {
+		name                   string
+		query                  string
+		expectedStatus         int
+		expectedCurrency       money.Currency
+		expectedOpeningBalance money.Amount
+		expectedRunning        []money.Amount
+		expectedClosingBalance money.Amount
+	}{
+		{
+			name:                   "valid request",
+			query:                  "?from=2025-10-01T00:00:00Z&to=2025-11-01T00:00:00Z",
+			expectedStatus:         http.StatusOK,
+			expectedCurrency:       "BRL",
+			expectedOpeningBalance: -1000,
+			expectedRunning:        []money.Amount{-6000, -4000},
+			expectedClosingBalance: -4000,
+		},
+		{
+			name:                   "other currency",
+			query:                  "?from=2025-10-01T00:00:00Z&to=2025-11-01T00:00:00Z&currency=JPY",
+			expectedStatus:         http.StatusOK,
+			expectedCurrency:       "JPY",
+			expectedOpeningBalance: -1000,
+			expectedRunning:        []money.Amount{-6000, -4000},
+			expectedClosingBalance: -4000,
+		},
+		{
+			name:           "unsupported currency",
+			query:          "?from=2025-10-01T00:00:00Z&to=2025-11-01T00:00:00Z&currency=XXX",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing from",
+			query:          "?to=2025-11-01T00:00:00Z",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid to",
+			query:          "?from=2025-10-01T00:00:00Z&to=november",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "to before from",
+			query:          "?from=2025-11-01T00:00:00Z&to=2025-10-01T00:00:00Z",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				sumAmountsBeforeFunc: func(ctx context.Context, accountId int, currency money.Currency, before time.Time) (int, error) {
+					if currency != tt.expectedCurrency {
+						return 0, errors.New("unexpected currency")
+					}
+					return -1000, nil
+				},
+				getByPeriodFunc: func(ctx context.Context, accountId int, currency money.Currency, from time.Time, to time.Time) ([]Transaction, error) {
+					return []Transaction{
+						{
+							ID:              pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+							OperationTypeId: 1,
+							Amount:          -5000,
+							EventDate:       time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC),
+						},
+						{
+							ID:              pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+							OperationTypeId: 4,
+							Amount:          2000,
+							EventDate:       time.Date(2025, 10, 2, 10, 0, 0, 0, time.UTC),
+						},
+					}, nil
+				},
+			}
+
+			mockAccountRepo := &mockAccountRepository{
+				getByIDFunc: func(ctx context.Context, id int) (account.Account, error) {
+					return account.Account{ID: id, Currency: money.DefaultCurrency}, nil
+				},
+			}
+
+			handler := NewHandler(validator.New(), &mockUnitOfWork{}, mockRepo, mockAccountRepo, &mockOperationTypeRepository{})
+
+			req := httptest.NewRequest(http.MethodGet, "/accounts/1/statement"+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("accountId", "1")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.Statement(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d. Response body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response StatementResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if response.Currency != string(tt.expectedCurrency) {
+				t.Errorf("expected currency %s, got %s", tt.expectedCurrency, response.Currency)
+			}
+
+			if expected := money.ToDecimal(tt.expectedOpeningBalance, tt.expectedCurrency); response.OpeningBalance != expected {
+				t.Errorf("expected opening balance %s, got %s", expected, response.OpeningBalance)
+			}
+
+			if len(response.Items) != len(tt.expectedRunning) {
+				t.Fatalf("expected %d items, got %d", len(tt.expectedRunning), len(response.Items))
+			}
+
+			for i, running := range tt.expectedRunning {
+				if expected := money.ToDecimal(running, tt.expectedCurrency); response.Items[i].RunningBalance != expected {
+					t.Errorf("item %d: expected running balance %s, got %s", i, expected, response.Items[i].RunningBalance)
+				}
+			}
+
+			if expected := money.ToDecimal(tt.expectedClosingBalance, tt.expectedCurrency); response.ClosingBalance != expected {
+				t.Errorf("expected closing balance %s, got %s", expected, response.ClosingBalance)
 			}
 		})
 	}
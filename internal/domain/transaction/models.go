@@ -5,26 +5,213 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rikw22/challenge-money/pkg/money"
 )
 
 type CreateTransactionRequest struct {
-	AccountId       int     `json:"account_id" validate:"required,gt=0"`
-	OperationTypeId int     `json:"operation_type_id" validate:"required,min=1,max=4"`
-	Amount          float64 `json:"amount" validate:"required,gt=0,max2decimals"`
+	AccountId       int           `json:"account_id" validate:"required,gt=0"`
+	OperationTypeId int           `json:"operation_type_id" validate:"required,gt=0"`
+	Amount          money.Decimal `json:"amount" validate:"required,gt=0"`
+	Currency        string        `json:"currency" validate:"omitempty,currency"`         // defaults to the account currency
+	Installments    int           `json:"installments" validate:"omitempty,min=1,max=48"` // only for operation types allowing installments
+}
+
+// ReverseTransactionRequest reverses part of a transaction, or all that is left of it when
+// the amount is not set. The amount is in the currency of the transaction.
+type ReverseTransactionRequest struct {
+	Amount money.Decimal `json:"amount" validate:"omitempty,gt=0"`
+}
+
+type CreateTransferRequest struct {
+	SourceAccountId      int           `json:"source_account_id" validate:"required,gt=0"`
+	DestinationAccountId int           `json:"destination_account_id" validate:"required,gt=0,nefield=SourceAccountId"`
+	Amount               money.Decimal `json:"amount" validate:"required,gt=0"` // in the currency of both accounts
 }
 
 type CreateTransactionResponse struct {
-	ID              uuid.UUID `json:"id"`
-	AccountId       int       `json:"account_id"`
-	OperationTypeId int       `json:"operation_type_id"`
-	Amount          float64   `json:"amount"`
+	ID              uuid.UUID             `json:"id"`
+	AccountId       int                   `json:"account_id"`
+	OperationTypeId int                   `json:"operation_type_id"`
+	Amount          money.Decimal         `json:"amount"`
+	Currency        string                `json:"currency"`
+	Installments    []InstallmentResponse `json:"installments,omitempty"`
 }
 
-type Transaction struct {
-	ID              pgtype.UUID
+type InstallmentResponse struct {
+	ID                uuid.UUID     `json:"id"`
+	InstallmentNumber int           `json:"installment_number"`
+	Amount            money.Decimal `json:"amount"`
+	Balance           money.Decimal `json:"balance"`
+	DueDate           string        `json:"due_date"`
+}
+
+type TransactionResponse struct {
+	ID              uuid.UUID     `json:"id"`
+	AccountId       int           `json:"account_id"`
+	OperationTypeId int           `json:"operation_type_id"`
+	Amount          money.Decimal `json:"amount"`
+	Balance         money.Decimal `json:"balance"`
+	Currency        string        `json:"currency"`
+	EventDate       string        `json:"event_date"`
+	ReversalOf      *uuid.UUID    `json:"reversal_of,omitempty"`
+	TransferID      *uuid.UUID    `json:"transfer_id,omitempty"`
+}
+
+type GetTransactionResponse struct {
+	ID                       uuid.UUID             `json:"id"`
+	AccountId                int                   `json:"account_id"`
+	OperationTypeId          int                   `json:"operation_type_id"`
+	OperationTypeDescription string                `json:"operation_type_description"`
+	Amount                   money.Decimal         `json:"amount"`
+	Balance                  money.Decimal         `json:"balance"`
+	Currency                 string                `json:"currency"`
+	EventDate                string                `json:"event_date"`
+	ReversalOf               *uuid.UUID            `json:"reversal_of,omitempty"`
+	TransferID               *uuid.UUID            `json:"transfer_id,omitempty"`
+	Installments             []InstallmentResponse `json:"installments,omitempty"`
+}
+
+// TransferResponse is a transfer with its two transactions, the debit on the source account first.
+type TransferResponse struct {
+	ID                   uuid.UUID             `json:"id"`
+	SourceAccountId      int                   `json:"source_account_id"`
+	DestinationAccountId int                   `json:"destination_account_id"`
+	Amount               money.Decimal         `json:"amount"`
+	Currency             string                `json:"currency"`
+	CreatedAt            string                `json:"created_at"`
+	Transactions         []TransactionResponse `json:"transactions"`
+}
+
+type SettlementResponse struct {
+	ID        uuid.UUID     `json:"id"`
+	PaymentID uuid.UUID     `json:"payment_id"`
+	DebitID   uuid.UUID     `json:"debit_id"`
+	Amount    money.Decimal `json:"amount"`
+	Currency  string        `json:"currency"`
+	SettledAt string        `json:"settled_at"`
+}
+
+type ListSettlementsResponse struct {
+	TransactionID uuid.UUID            `json:"transaction_id"`
+	Data          []SettlementResponse `json:"data"`
+}
+
+type ListTransactionsResponse struct {
+	Data       []TransactionResponse `json:"data"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+type BalanceResponse struct {
+	AccountId int                       `json:"account_id"`
+	Balances  []CurrencyBalanceResponse `json:"balances"`
+}
+
+type CurrencyBalanceResponse struct {
+	Currency        string                       `json:"currency"`
+	OutstandingDebt money.Decimal                `json:"outstanding_debt"`
+	ScheduledDebt   money.Decimal                `json:"scheduled_debt"`
+	AvailableCredit money.Decimal                `json:"available_credit"`
+	Totals          []OperationTypeTotalResponse `json:"totals"`
+}
+
+type OperationTypeTotalResponse struct {
+	OperationTypeId int           `json:"operation_type_id"`
+	Count           int           `json:"count"`
+	Amount          money.Decimal `json:"amount"`
+}
+
+type StatementResponse struct {
+	AccountId      int                     `json:"account_id"`
+	From           string                  `json:"from"`
+	To             string                  `json:"to"`
+	Currency       string                  `json:"currency"`
+	OpeningBalance money.Decimal           `json:"opening_balance"`
+	Items          []StatementItemResponse `json:"items"`
+	ClosingBalance money.Decimal           `json:"closing_balance"`
+}
+
+type StatementItemResponse struct {
+	ID              uuid.UUID     `json:"id"`
+	OperationTypeId int           `json:"operation_type_id"`
+	Amount          money.Decimal `json:"amount"`
+	RunningBalance  money.Decimal `json:"running_balance"`
+	EventDate       string        `json:"event_date"`
+}
+
+// ListFilter selects a page of transactions of an account. Pointer and zero fields are not applied.
+// Amounts are absolute values in minor units.
+type ListFilter struct {
 	AccountId       int
 	OperationTypeId int
+	Currency        money.Currency
+	From            *time.Time
+	To              *time.Time
+	MinAmount       *int
+	MaxAmount       *int
+	Cursor          pgtype.UUID
+	Limit           int
+}
+
+type Transaction struct {
+	ID                       pgtype.UUID
+	AccountId                int
+	OperationTypeId          int
+	OperationTypeDescription string
+	Amount                   int
+	Balance                  int
+	Currency                 money.Currency // amounts are in minor units of the currency
+	EventDate                time.Time      // due date of installments
+
+	// An installment purchase is stored as a parent row holding the whole amount and
+	// a child row per installment, numbered from 1, which are the ones carrying a balance.
+	ParentID          pgtype.UUID
+	Installments      int
+	InstallmentNumber int
+
+	// ReversalOf is the transaction compensated by a reversal, which has the opposite sign.
+	ReversalOf pgtype.UUID
+	// TransferID is the transfer the transaction is a leg of.
+	TransferID pgtype.UUID
+}
+
+// Settlement is Amount of the balance of the payment applied to the debt of the debit.
+type Settlement struct {
+	ID        pgtype.UUID
+	PaymentID pgtype.UUID
+	DebitID   pgtype.UUID
+	Amount    int
+	CreatedAt time.Time
+}
+
+// Transfer moves Amount, in minor units of Currency, from the source to the destination account.
+type Transfer struct {
+	ID                   pgtype.UUID
+	SourceAccountId      int
+	DestinationAccountId int
+	Amount               int
+	Currency             money.Currency
+	CreatedAt            time.Time
+}
+
+// AccountCurrency is an account and one of the currencies it holds balances in.
+type AccountCurrency struct {
+	AccountId int
+	Currency  money.Currency
+}
+
+// Balance is the current position of an account in one currency, in minor units. OutstandingDebt
+// is the sum of the open negative balances already due, ScheduledDebt the sum of the installments
+// not due yet and AvailableCredit the unused remainder of credit vouchers.
+type Balance struct {
+	Currency        money.Currency
+	OutstandingDebt int
+	ScheduledDebt   int
+	AvailableCredit int
+	Totals          []OperationTypeTotal
+}
+
+type OperationTypeTotal struct {
+	OperationTypeId int
+	Count           int
 	Amount          int
-	Balance         int
-	EventDate       time.Time
 }
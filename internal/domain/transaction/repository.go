@@ -2,18 +2,52 @@ package transaction
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rikw22/challenge-money/internal/common/database"
+	"github.com/rikw22/challenge-money/pkg/money"
 )
 
 type Repository interface {
+	GetByID(ctx context.Context, id pgtype.UUID) (Transaction, error)
 	Create(ctx context.Context, transaction *Transaction) error
-	GetTransactionsWithNegativeBalance(ctx context.Context, accountId int) ([]Transaction, error)
-	UpdateTransactionBalance(ctx context.Context, uuid pgtype.UUID, balance int) error
+	LockAccount(ctx context.Context, accountId int) error
+	GetTransactionsWithNegativeBalance(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error)
+	GetTransactionsWithPositiveBalance(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error)
+	GetAccountsWithDueDebtsAndCredit(ctx context.Context, dueBy time.Time) ([]AccountCurrency, error)
+	Settle(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error
+	GetSettlements(ctx context.Context, transactionId pgtype.UUID) ([]Settlement, error)
+	GetInstallments(ctx context.Context, parentId pgtype.UUID) ([]Transaction, error)
+	SumReversals(ctx context.Context, id pgtype.UUID) (int, error)
+	CreateTransfer(ctx context.Context, transfer *Transfer) error
+	GetTransfer(ctx context.Context, id pgtype.UUID) (Transfer, error)
+	GetByTransfer(ctx context.Context, transferId pgtype.UUID) ([]Transaction, error)
+	List(ctx context.Context, filter ListFilter) ([]Transaction, error)
+	GetBalance(ctx context.Context, accountId int, asOf time.Time) ([]Balance, error)
+	SumAmountsBefore(ctx context.Context, accountId int, currency money.Currency, before time.Time) (int, error)
+	GetByPeriod(ctx context.Context, accountId int, currency money.Currency, from time.Time, to time.Time) ([]Transaction, error)
 }
 
+// transactionColumns are the columns read by queryTransactions.
+const transactionColumns = `id, account_id, operationtype_id, amount, COALESCE(balance, 0), currency, eventdate,
+	parent_id, installments, COALESCE(installment_number, 0), reversal_of, transfer_id`
+
+// postedCondition leaves out the parent rows of installment purchases, whose amount is
+// already accounted for by their installments.
+const postedCondition = `(parent_id IS NOT NULL OR installments = 1)`
+
+// unusedCreditCondition keeps the transactions with unused credit that settles debts: discharging
+// credits and reversals of debits, which discharge like payments. Other credits keep their whole
+// amount.
+const unusedCreditCondition = `balance > 0 AND (reversal_of IS NOT NULL OR EXISTS (SELECT 1 FROM operationtype o
+	WHERE o.id = operationtype_id AND o.discharges_balance))`
+
 type pgxRepository struct {
 	db *pgxpool.Pool
 }
@@ -22,14 +56,64 @@ func NewRepository(db *pgxpool.Pool) Repository {
 	return &pgxRepository{db: db}
 }
 
+func (r pgxRepository) GetByID(ctx context.Context, id pgtype.UUID) (Transaction, error) {
+	query := `
+		SELECT t.id, t.account_id, t.operationtype_id, o.description, t.amount, COALESCE(t.balance, 0), t.currency, t.eventdate,
+			   t.parent_id, t.installments, COALESCE(t.installment_number, 0), t.reversal_of, t.transfer_id
+		FROM transaction t
+		JOIN operationtype o ON o.id = t.operationtype_id
+		WHERE t.id=$1
+	`
+
+	var t Transaction
+	err := database.Conn(ctx, r.db).QueryRow(ctx, query, id).Scan(
+		&t.ID,
+		&t.AccountId,
+		&t.OperationTypeId,
+		&t.OperationTypeDescription,
+		&t.Amount,
+		&t.Balance,
+		&t.Currency,
+		&t.EventDate,
+		&t.ParentID,
+		&t.Installments,
+		&t.InstallmentNumber,
+		&t.ReversalOf,
+		&t.TransferID,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Transaction{}, ErrNotFound
+	}
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	return t, nil
+}
+
+// Create stores the transaction and, unless its balance is zero, opens the balance in the journal
+// with an entry posting it to the transaction against the external ledger.
 func (r pgxRepository) Create(ctx context.Context, t *Transaction) error {
 	query := `
-		INSERT INTO transaction (account_id, operationtype_id, amount, eventdate)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, eventdate
+		WITH inserted AS (
+			INSERT INTO transaction (account_id, operationtype_id, amount, balance, currency, eventdate,
+									 parent_id, installments, installment_number, reversal_of, transfer_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, GREATEST($8, 1), NULLIF($9, 0), $10, $11)
+			RETURNING id, eventdate
+		), entry AS (
+			INSERT INTO journal_entry (kind)
+			SELECT 'transaction' WHERE $4::integer <> 0
+			RETURNING id
+		), postings AS (
+			INSERT INTO posting (journal_entry_id, ledger, transaction_id, amount)
+			SELECT entry.id, p.ledger, inserted.id, p.amount
+			FROM entry, inserted, (VALUES ('balance', $4::integer), ('external', -$4::integer)) AS p(ledger, amount)
+		)
+		SELECT id, eventdate FROM inserted
 	`
 
-	row := r.db.QueryRow(ctx, query, t.AccountId, t.OperationTypeId, t.Amount, t.EventDate)
+	row := database.Conn(ctx, r.db).QueryRow(ctx, query, t.AccountId, t.OperationTypeId, t.Amount, t.Balance, t.Currency, t.EventDate,
+		t.ParentID, t.Installments, t.InstallmentNumber, t.ReversalOf, t.TransferID)
 	err := row.Scan(
 		&t.ID,
 		&t.EventDate,
@@ -41,13 +125,77 @@ func (r pgxRepository) Create(ctx context.Context, t *Transaction) error {
 	return nil
 }
 
-func (r *pgxRepository) GetTransactionsWithNegativeBalance(ctx context.Context, accountId int) ([]Transaction, error) {
+// LockAccount takes a row lock on the account until the surrounding unit of work ends,
+// serializing concurrent transactions of the same account.
+func (r pgxRepository) LockAccount(ctx context.Context, accountId int) error {
+	query := `SELECT id FROM account WHERE id=$1 FOR UPDATE`
+
+	var id int
+	err := database.Conn(ctx, r.db).QueryRow(ctx, query, accountId).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to lock account: %w", err)
+	}
+
+	return nil
+}
+
+// GetTransactionsWithNegativeBalance returns the open debts due by the given date, which leaves
+// out the installments that have not fallen due yet.
+func (r *pgxRepository) GetTransactionsWithNegativeBalance(ctx context.Context, accountId int, currency money.Currency, dueBy time.Time) ([]Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transaction WHERE account_id=$1 AND currency=$2 AND balance < 0 AND eventdate <= $3
+		ORDER BY eventdate ASC
+		`
+	return r.queryTransactions(ctx, query, accountId, currency, dueBy)
+}
+
+// GetTransactionsWithPositiveBalance returns the transactions of the account in the currency with
+// unused credit that settles debts, oldest first.
+func (r *pgxRepository) GetTransactionsWithPositiveBalance(ctx context.Context, accountId int, currency money.Currency) ([]Transaction, error) {
 	query := `
-		SELECT id, account_id, operationtype_id, amount, balance, eventdate 
-		FROM transaction WHERE account_id=$1 AND balance < 0
+		SELECT ` + transactionColumns + `
+		FROM transaction WHERE account_id=$1 AND currency=$2 AND ` + unusedCreditCondition + `
 		ORDER BY eventdate ASC
 		`
-	rows, err := r.db.Query(ctx, query, accountId)
+	return r.queryTransactions(ctx, query, accountId, currency)
+}
+
+// GetAccountsWithDueDebtsAndCredit returns the accounts holding, in the same currency, debts due by
+// the given date and unused discharging credit, which happens when installments fall due.
+func (r *pgxRepository) GetAccountsWithDueDebtsAndCredit(ctx context.Context, dueBy time.Time) ([]AccountCurrency, error) {
+	query := `
+		SELECT DISTINCT d.account_id, d.currency
+		FROM transaction d
+		WHERE d.balance < 0 AND d.eventdate <= $1
+		  AND EXISTS (SELECT 1 FROM transaction
+					  WHERE account_id = d.account_id AND currency = d.currency AND ` + unusedCreditCondition + `)
+		ORDER BY d.account_id, d.currency
+		`
+
+	rows, err := database.Conn(ctx, r.db).Query(ctx, query, dueBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts with due debts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []AccountCurrency
+	for rows.Next() {
+		var a AccountCurrency
+		if err := rows.Scan(&a.AccountId, &a.Currency); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get accounts with due debts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+func (r *pgxRepository) queryTransactions(ctx context.Context, query string, args ...any) ([]Transaction, error) {
+	rows, err := database.Conn(ctx, r.db).Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get rows: %w", err)
 	}
@@ -56,26 +204,291 @@ func (r *pgxRepository) GetTransactionsWithNegativeBalance(ctx context.Context,
 	var transactions []Transaction
 	for rows.Next() {
 		var t Transaction
-		rows.Scan(
+		err := rows.Scan(
 			&t.ID,
 			&t.AccountId,
 			&t.OperationTypeId,
 			&t.Amount,
 			&t.Balance,
+			&t.Currency,
 			&t.EventDate,
+			&t.ParentID,
+			&t.Installments,
+			&t.InstallmentNumber,
+			&t.ReversalOf,
+			&t.TransferID,
 		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
 
 		transactions = append(transactions, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get rows: %w", err)
+	}
 
 	return transactions, nil
 }
 
-func (r pgxRepository) UpdateTransactionBalance(ctx context.Context, uuid pgtype.UUID, balance int) error {
-	query := `UPDATE transaction SET balance=$1 WHERE id=$2`
-	_, err := r.db.Query(ctx, query, balance, uuid)
+// Settle applies amount of the balance of a credit to the debt of a debit. It is recorded in the
+// journal as a settlement entry moving the amount between the balances of both transactions,
+// along with its settlement row, and the balance columns are updated in the same statement.
+func (r pgxRepository) Settle(ctx context.Context, debitId pgtype.UUID, creditId pgtype.UUID, amount int) error {
+	query := `
+		WITH entry AS (
+			INSERT INTO journal_entry (kind) VALUES ('settlement')
+			RETURNING id
+		), postings AS (
+			INSERT INTO posting (journal_entry_id, ledger, transaction_id, amount)
+			SELECT entry.id, 'balance', p.transaction_id, p.amount
+			FROM entry, (VALUES ($1::uuid, $3::integer), ($2::uuid, -$3::integer)) AS p(transaction_id, amount)
+		), settled AS (
+			INSERT INTO settlement (journal_entry_id, payment_id, debit_id, amount)
+			SELECT entry.id, $2, $1, $3 FROM entry
+		)
+		UPDATE transaction SET balance = balance + CASE WHEN id=$1 THEN $3::integer ELSE -$3::integer END
+		WHERE id IN ($1, $2)
+	`
+
+	tag, err := database.Conn(ctx, r.db).Exec(ctx, query, debitId, creditId, amount)
 	if err != nil {
-		return fmt.Errorf("failed to update balance: %w", err)
+		return fmt.Errorf("failed to settle transaction: %w", err)
+	}
+	if tag.RowsAffected() != 2 {
+		return fmt.Errorf("failed to settle transaction: %d balances updated", tag.RowsAffected())
 	}
+
 	return nil
 }
+
+// GetSettlements returns the settlements where the transaction is either the payment or the
+// debit, oldest first.
+func (r *pgxRepository) GetSettlements(ctx context.Context, transactionId pgtype.UUID) ([]Settlement, error) {
+	query := `
+		SELECT id, payment_id, debit_id, amount, created_at
+		FROM settlement WHERE payment_id=$1 OR debit_id=$1
+		ORDER BY created_at ASC, id ASC
+		`
+
+	rows, err := database.Conn(ctx, r.db).Query(ctx, query, transactionId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settlements: %w", err)
+	}
+	defer rows.Close()
+
+	settlements := []Settlement{}
+	for rows.Next() {
+		var s Settlement
+		if err := rows.Scan(&s.ID, &s.PaymentID, &s.DebitID, &s.Amount, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		settlements = append(settlements, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get settlements: %w", err)
+	}
+
+	return settlements, nil
+}
+
+// GetInstallments returns the installments of an installment purchase, in order.
+func (r *pgxRepository) GetInstallments(ctx context.Context, parentId pgtype.UUID) ([]Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transaction WHERE parent_id=$1
+		ORDER BY installment_number ASC
+		`
+	return r.queryTransactions(ctx, query, parentId)
+}
+
+// SumReversals returns the absolute amount already reversed of a transaction.
+func (r *pgxRepository) SumReversals(ctx context.Context, id pgtype.UUID) (int, error) {
+	query := `SELECT COALESCE(SUM(ABS(amount)), 0) FROM transaction WHERE reversal_of=$1`
+
+	var sum int
+	if err := database.Conn(ctx, r.db).QueryRow(ctx, query, id).Scan(&sum); err != nil {
+		return 0, fmt.Errorf("failed to sum reversals: %w", err)
+	}
+
+	return sum, nil
+}
+
+func (r *pgxRepository) CreateTransfer(ctx context.Context, t *Transfer) error {
+	query := `
+		INSERT INTO transfer (source_account_id, destination_account_id, amount, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := database.Conn(ctx, r.db).QueryRow(ctx, query, t.SourceAccountId, t.DestinationAccountId, t.Amount, t.Currency, t.CreatedAt).Scan(
+		&t.ID,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (r *pgxRepository) GetTransfer(ctx context.Context, id pgtype.UUID) (Transfer, error) {
+	query := `SELECT id, source_account_id, destination_account_id, amount, currency, created_at FROM transfer WHERE id=$1`
+
+	var t Transfer
+	err := database.Conn(ctx, r.db).QueryRow(ctx, query, id).Scan(
+		&t.ID,
+		&t.SourceAccountId,
+		&t.DestinationAccountId,
+		&t.Amount,
+		&t.Currency,
+		&t.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Transfer{}, ErrTransferNotFound
+	}
+	if err != nil {
+		return Transfer{}, fmt.Errorf("failed to get transfer: %w", err)
+	}
+
+	return t, nil
+}
+
+// GetByTransfer returns the transactions of a transfer, the debit first.
+func (r *pgxRepository) GetByTransfer(ctx context.Context, transferId pgtype.UUID) ([]Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transaction WHERE transfer_id=$1
+		ORDER BY amount ASC
+		`
+	return r.queryTransactions(ctx, query, transferId)
+}
+
+// List returns transactions ordered by id, which being UUIDv7 follows creation order.
+// Installments are not listed, only the purchase they belong to.
+// Filter.Cursor, when set, is the id of the last transaction of the previous page.
+func (r *pgxRepository) List(ctx context.Context, filter ListFilter) ([]Transaction, error) {
+	conditions := []string{"account_id=$1", "parent_id IS NULL"}
+	args := []any{filter.AccountId}
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Cursor.Valid {
+		addCondition("id > $%d", filter.Cursor)
+	}
+	if filter.OperationTypeId != 0 {
+		addCondition("operationtype_id=$%d", filter.OperationTypeId)
+	}
+	if filter.Currency != "" {
+		addCondition("currency=$%d", filter.Currency)
+	}
+	if filter.From != nil {
+		addCondition("eventdate >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("eventdate < $%d", *filter.To)
+	}
+	if filter.MinAmount != nil {
+		addCondition("ABS(amount) >= $%d", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		addCondition("ABS(amount) <= $%d", *filter.MaxAmount)
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT `+transactionColumns+`
+		FROM transaction WHERE %s
+		ORDER BY id ASC
+		LIMIT $%d
+		`, strings.Join(conditions, " AND "), len(args))
+
+	return r.queryTransactions(ctx, query, args...)
+}
+
+// GetBalance returns the position of the account in each currency it holds, ordered by currency.
+// Debts falling due after asOf, such as future installments, are reported as scheduled.
+func (r *pgxRepository) GetBalance(ctx context.Context, accountId int, asOf time.Time) ([]Balance, error) {
+	query := `
+		SELECT currency,
+			   COALESCE(-SUM(balance) FILTER (WHERE balance < 0 AND eventdate <= $2), 0),
+			   COALESCE(-SUM(balance) FILTER (WHERE balance < 0 AND eventdate > $2), 0),
+			   COALESCE(SUM(balance) FILTER (WHERE balance > 0), 0)
+		FROM transaction WHERE account_id=$1
+		GROUP BY currency
+		ORDER BY currency
+	`
+	rows, err := database.Conn(ctx, r.db).Query(ctx, query, accountId, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	defer rows.Close()
+
+	var balances []Balance
+	for rows.Next() {
+		var b Balance
+		if err := rows.Scan(&b.Currency, &b.OutstandingDebt, &b.ScheduledDebt, &b.AvailableCredit); err != nil {
+			return nil, fmt.Errorf("failed to scan balance: %w", err)
+		}
+		balances = append(balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	query = `
+		SELECT currency, operationtype_id, COUNT(id), ABS(SUM(amount))
+		FROM transaction WHERE account_id=$1 AND parent_id IS NULL
+		GROUP BY currency, operationtype_id
+		ORDER BY currency, operationtype_id
+	`
+	rows, err = database.Conn(ctx, r.db).Query(ctx, query, accountId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get totals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var currency money.Currency
+		var total OperationTypeTotal
+		if err := rows.Scan(&currency, &total.OperationTypeId, &total.Count, &total.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan total: %w", err)
+		}
+		for i := range balances {
+			if balances[i].Currency == currency {
+				balances[i].Totals = append(balances[i].Totals, total)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get totals: %w", err)
+	}
+
+	return balances, nil
+}
+
+func (r *pgxRepository) SumAmountsBefore(ctx context.Context, accountId int, currency money.Currency, before time.Time) (int, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transaction WHERE account_id=$1 AND currency=$2 AND eventdate < $3 AND ` + postedCondition
+
+	var sum int
+	err := database.Conn(ctx, r.db).QueryRow(ctx, query, accountId, currency, before).Scan(&sum)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum amounts: %w", err)
+	}
+
+	return sum, nil
+}
+
+func (r *pgxRepository) GetByPeriod(ctx context.Context, accountId int, currency money.Currency, from time.Time, to time.Time) ([]Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transaction WHERE account_id=$1 AND currency=$2 AND eventdate >= $3 AND eventdate < $4 AND ` + postedCondition + `
+		ORDER BY eventdate ASC, id ASC
+		`
+	return r.queryTransactions(ctx, query, accountId, currency, from, to)
+}
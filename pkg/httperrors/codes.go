@@ -0,0 +1,48 @@
+package httperrors
+
+// Code is a stable application error code that clients can rely on, unlike the error messages.
+type Code string
+
+const (
+	// CodeInvalidRequest is set when the request cannot be decoded or is otherwise malformed.
+	CodeInvalidRequest Code = "INVALID_REQUEST"
+	// CodeValidationFailed is set when one or more fields fail validation, see ErrResponse.Details.
+	CodeValidationFailed Code = "VALIDATION_FAILED"
+	// CodeNotFound is set when the requested resource does not exist.
+	CodeNotFound Code = "NOT_FOUND"
+	// CodeAccountNotFound is set when the account referenced by the request does not exist.
+	CodeAccountNotFound Code = "ACCOUNT_NOT_FOUND"
+	// CodeAccountBlocked is set when a debit is made on a blocked account.
+	CodeAccountBlocked Code = "ACCOUNT_BLOCKED"
+	// CodeAccountClosed is set when a transaction other than a debt settlement, or exceeding the debt due,
+	// is made on a closed account.
+	CodeAccountClosed Code = "ACCOUNT_CLOSED"
+	// CodeInvalidStatusTransition is set when the account cannot change from its current status to the requested one.
+	CodeInvalidStatusTransition Code = "INVALID_STATUS_TRANSITION"
+	// CodeTransactionNotFound is set when the transaction referenced by the request does not exist.
+	CodeTransactionNotFound Code = "TRANSACTION_NOT_FOUND"
+	// CodeTransferNotFound is set when the transfer referenced by the request does not exist.
+	CodeTransferNotFound Code = "TRANSFER_NOT_FOUND"
+	// CodeOperationTypeUnknown is set when the operation type referenced by the request does not exist.
+	CodeOperationTypeUnknown Code = "OPERATION_TYPE_UNKNOWN"
+	// CodeOperationTypeInactive is set when the operation type referenced by the request has been deactivated.
+	CodeOperationTypeInactive Code = "OPERATION_TYPE_INACTIVE"
+	// CodeOperationTypeUnavailable is set when there is no active operation type of the kind the request needs.
+	CodeOperationTypeUnavailable Code = "OPERATION_TYPE_UNAVAILABLE"
+	// CodeDuplicateDocument is set when an account with the same document number already exists.
+	CodeDuplicateDocument Code = "DUPLICATE_DOCUMENT"
+	// CodeCurrencyMismatch is set when a debit is not in the currency of the account.
+	CodeCurrencyMismatch Code = "CURRENCY_MISMATCH"
+	// CodeInsufficientLimit is set when a debit exceeds the available limit of the account.
+	CodeInsufficientLimit Code = "INSUFFICIENT_LIMIT"
+	// CodeReversalNotAllowed is set when the transaction to reverse is a reversal, an installment purchase or part of a transfer.
+	CodeReversalNotAllowed Code = "REVERSAL_NOT_ALLOWED"
+	// CodeReversalExceedsAmount is set when a reversal exceeds what is left to reverse of the transaction.
+	CodeReversalExceedsAmount Code = "REVERSAL_EXCEEDS_AMOUNT"
+	// CodeIdempotencyKeyReused is set when an idempotency key is sent again with a different payload.
+	CodeIdempotencyKeyReused Code = "IDEMPOTENCY_KEY_REUSED"
+	// CodeIdempotencyKeyInProgress is set when an idempotency key is sent again before its first request finished.
+	CodeIdempotencyKeyInProgress Code = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	// CodeInternal is set on unexpected server errors, whose details are only logged.
+	CodeInternal Code = "INTERNAL_ERROR"
+)
@@ -1,9 +1,15 @@
 package httperrors
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 // ErrResponse represents an HTTP error response with status code and error details.
@@ -11,29 +17,78 @@ type ErrResponse struct {
 	Err            error `json:"-"` // low-level runtime error
 	HTTPStatusCode int   `json:"-"` // httperrors response status code
 
-	StatusText string `json:"status"`          // user-level status message
-	AppCode    int64  `json:"code,omitempty"`  // application-specific error code
-	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
+	StatusText    string         `json:"status"`                   // user-level status message
+	AppCode       Code           `json:"code,omitempty"`           // application-specific error code
+	ErrorText     string         `json:"error,omitempty"`          // application-level error message, for debugging
+	Details       []FieldError   `json:"details,omitempty"`        // field-level validation errors
+	Meta          map[string]any `json:"meta,omitempty"`           // error specific data, such as the id of a conflicting resource
+	CorrelationID string         `json:"correlation_id,omitempty"` // identifies the server log entry of internal errors
 }
 
-// Render sets the HTTP status code for the response.
+// FieldError describes a request field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Render sets the HTTP status code for the response. Internal errors are logged together
+// with a correlation id, which is the only detail sent to the client.
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	if e.HTTPStatusCode >= http.StatusInternalServerError {
+		e.CorrelationID = middleware.GetReqID(r.Context())
+		if e.CorrelationID == "" {
+			e.CorrelationID = uuid.NewString()
+		}
+		log.Printf("[%s] %s %s: %v", e.CorrelationID, r.Method, r.URL.Path, e.Err)
+	}
+
 	render.Status(r, e.HTTPStatusCode)
 	return nil
 }
 
-// ErrInvalidRequest returns a 400 Bad Request error response.
-func ErrInvalidRequest(err error) render.Renderer {
-	return &ErrResponse{
+// WithCode returns a copy of the error response with the given application code.
+func (e ErrResponse) WithCode(code Code) *ErrResponse {
+	e.AppCode = code
+	return &e
+}
+
+// WithMeta returns a copy of the error response with key set to value in its metadata.
+func (e ErrResponse) WithMeta(key string, value any) *ErrResponse {
+	meta := make(map[string]any, len(e.Meta)+1)
+	for k, v := range e.Meta {
+		meta[k] = v
+	}
+	meta[key] = value
+	e.Meta = meta
+	return &e
+}
+
+// ErrInvalidRequest returns a 400 Bad Request error response. Validation errors are
+// reported field by field.
+func ErrInvalidRequest(err error) *ErrResponse {
+	resp := &ErrResponse{
 		Err:            err,
 		HTTPStatusCode: http.StatusBadRequest,
 		StatusText:     "Invalid request.",
+		AppCode:        CodeInvalidRequest,
 		ErrorText:      err.Error(),
 	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		resp.AppCode = CodeValidationFailed
+		resp.ErrorText = "One or more fields are invalid."
+		for _, fe := range validationErrs {
+			resp.Details = append(resp.Details, newFieldError(fe))
+		}
+	}
+
+	return resp
 }
 
 // ErrRender returns a 422 Unprocessable Entity error response.
-func ErrRender(err error) render.Renderer {
+func ErrRender(err error) *ErrResponse {
 	return &ErrResponse{
 		Err:            err,
 		HTTPStatusCode: http.StatusUnprocessableEntity,
@@ -46,14 +101,49 @@ func ErrRender(err error) render.Renderer {
 var ErrNotFound = &ErrResponse{
 	HTTPStatusCode: http.StatusNotFound,
 	StatusText:     "Resource not found.",
+	AppCode:        CodeNotFound,
 }
 
-// ErrInternalServer returns a 500 Internal Server Error response.
-func ErrInternalServer(err error) render.Renderer {
+// ErrInternalServer returns a 500 Internal Server Error response. The error is logged by
+// Render and never sent to the client.
+func ErrInternalServer(err error) *ErrResponse {
 	return &ErrResponse{
 		Err:            err,
 		HTTPStatusCode: http.StatusInternalServerError,
 		StatusText:     "Internal server error.",
+		AppCode:        CodeInternal,
+	}
+}
+
+// ErrConflict returns a 409 Conflict error response.
+func ErrConflict(err error) *ErrResponse {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     "Conflict.",
 		ErrorText:      err.Error(),
 	}
 }
+
+// ErrUnprocessableEntity returns a 422 Unprocessable Entity error response.
+func ErrUnprocessableEntity(err error) *ErrResponse {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusUnprocessableEntity,
+		StatusText:     "Unprocessable entity.",
+		ErrorText:      err.Error(),
+	}
+}
+
+func newFieldError(fe validator.FieldError) FieldError {
+	message := fmt.Sprintf("must satisfy the '%s' rule", fe.Tag())
+	if fe.Param() != "" {
+		message = fmt.Sprintf("must satisfy the '%s=%s' rule", fe.Tag(), fe.Param())
+	}
+
+	return FieldError{
+		Field:   fe.Field(),
+		Rule:    fe.Tag(),
+		Message: message,
+	}
+}
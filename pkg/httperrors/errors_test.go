@@ -0,0 +1,226 @@
+package httperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestErrInvalidRequest(t *testing.T) {
+	type request struct {
+		DocumentNumber string `json:"document_number" validate:"required"`
+		Amount         int    `json:"amount" validate:"gt=0"`
+	}
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("json"), ",")[0]
+	})
+
+	tests := []struct {
+		name            string
+		err             error
+		expectedCode    Code
+		expectedDetails []FieldError
+	}{
+		{
+			name:         "decoding error",
+			err:          errors.New("unexpected EOF"),
+			expectedCode: CodeInvalidRequest,
+		},
+		{
+			name:         "validation errors",
+			err:          validate.Struct(&request{}),
+			expectedCode: CodeValidationFailed,
+			expectedDetails: []FieldError{
+				{Field: "document_number", Rule: "required", Message: "must satisfy the 'required' rule"},
+				{Field: "amount", Rule: "gt", Message: "must satisfy the 'gt=0' rule"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ErrInvalidRequest(tt.err)
+
+			if resp.HTTPStatusCode != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, resp.HTTPStatusCode)
+			}
+
+			if resp.AppCode != tt.expectedCode {
+				t.Errorf("expected code %s, got %s", tt.expectedCode, resp.AppCode)
+			}
+
+			if len(resp.Details) != len(tt.expectedDetails) {
+				t.Fatalf("expected %d details, got %d", len(tt.expectedDetails), len(resp.Details))
+			}
+
+			for i, expected := range tt.expectedDetails {
+				if resp.Details[i] != expected {
+					t.Errorf("detail %d: expected %+v, got %+v", i, expected, resp.Details[i])
+				}
+			}
+		})
+	}
+}
+
+func TestErrInternalServer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/transactions", nil)
+	w := httptest.NewRecorder()
+
+	render.Render(w, req, ErrInternalServer(errors.New(`pq: relation "transaction" does not exist`)))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if strings.Contains(w.Body.String(), "does not exist") {
+		t.Errorf("expected database error to be hidden, got %s", w.Body.String())
+	}
+
+	var response ErrResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.AppCode != CodeInternal {
+		t.Errorf("expected code %s, got %s", CodeInternal, response.AppCode)
+	}
+
+	if response.CorrelationID == "" {
+		t.Error("expected non-empty correlation_id in response")
+	}
+}
+
+func TestErrResponse_WithCode(t *testing.T) {
+	resp := ErrNotFound.WithCode(CodeAccountNotFound)
+
+	if resp.AppCode != CodeAccountNotFound {
+		t.Errorf("expected code %s, got %s", CodeAccountNotFound, resp.AppCode)
+	}
+
+	if ErrNotFound.AppCode != CodeNotFound {
+		t.Errorf("expected shared ErrNotFound to keep code %s, got %s", CodeNotFound, ErrNotFound.AppCode)
+	}
+}
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		name                string
+		accept              string
+		expectedContentType string
+		expectedProblem     bool
+	}{
+		{
+			name:                "no accept header keeps the default shape",
+			accept:              "",
+			expectedContentType: "application/json",
+			expectedProblem:     false,
+		},
+		{
+			name:                "wildcard keeps the default shape",
+			accept:              "*/*",
+			expectedContentType: "application/json",
+			expectedProblem:     false,
+		},
+		{
+			name:                "problem json requested",
+			accept:              "application/problem+json",
+			expectedContentType: ProblemContentType,
+			expectedProblem:     true,
+		},
+		{
+			name:                "problem json preferred over json",
+			accept:              "application/json;q=0.5, application/problem+json",
+			expectedContentType: ProblemContentType,
+			expectedProblem:     true,
+		},
+		{
+			name:                "json preferred over problem json",
+			accept:              "application/problem+json;q=0.5, application/json",
+			expectedContentType: "application/json",
+			expectedProblem:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/accounts/999?expand=true", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			resp := ErrNotFound.WithCode(CodeAccountNotFound)
+			if err := resp.Render(w, req); err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			Respond(w, req, resp)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, tt.expectedContentType) {
+				t.Errorf("expected content type %s, got %s", tt.expectedContentType, contentType)
+			}
+
+			if !tt.expectedProblem {
+				var response ErrResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if response.StatusText != "Resource not found." {
+					t.Errorf("expected default error shape, got %+v", response)
+				}
+				return
+			}
+
+			var problem Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			expected := Problem{
+				Type:     "/problems/account-not-found",
+				Title:    "Not Found",
+				Status:   http.StatusNotFound,
+				Instance: "/accounts/999?expand=true",
+				Code:     CodeAccountNotFound,
+			}
+			if problem.Type != expected.Type || problem.Title != expected.Title || problem.Status != expected.Status ||
+				problem.Instance != expected.Instance || problem.Code != expected.Code {
+				t.Errorf("expected problem %+v, got %+v", expected, problem)
+			}
+		})
+	}
+}
+
+func TestNewProblem_FieldErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/accounts", nil)
+	resp := &ErrResponse{
+		HTTPStatusCode: http.StatusBadRequest,
+		AppCode:        CodeValidationFailed,
+		ErrorText:      "One or more fields are invalid.",
+		Details: []FieldError{
+			{Field: "document_number", Rule: "required", Message: "must satisfy the 'required' rule"},
+		},
+	}
+
+	body, err := json.Marshal(NewProblem(resp, req))
+	if err != nil {
+		t.Fatalf("failed to marshal problem: %v", err)
+	}
+
+	expected := `{"type":"/problems/validation-failed","title":"Bad Request","status":400,"detail":"One or more fields are invalid.","instance":"/accounts","code":"VALIDATION_FAILED","errors":[{"field":"document_number","rule":"required","message":"must satisfy the 'required' rule"}]}`
+	if string(body) != expected {
+		t.Errorf("expected problem %s, got %s", expected, body)
+	}
+}
@@ -0,0 +1,101 @@
+package httperrors
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details documents.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBaseURI prefixes the lower-cased application code to build the problem type URI.
+var ProblemTypeBaseURI = "/problems/"
+
+// Problem is an RFC 9457 problem details document. Code, Errors, Meta and CorrelationID are
+// extension members.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          Code           `json:"code,omitempty"`
+	Errors        []FieldError   `json:"errors,omitempty"`
+	Meta          map[string]any `json:"meta,omitempty"`
+	CorrelationID string         `json:"correlation_id,omitempty"`
+}
+
+// NewProblem converts an error response into a problem details document for the request.
+func NewProblem(e *ErrResponse, r *http.Request) Problem {
+	problemType := "about:blank"
+	if e.AppCode != "" {
+		problemType = ProblemTypeBaseURI + strings.ToLower(strings.ReplaceAll(string(e.AppCode), "_", "-"))
+	}
+
+	return Problem{
+		Type:          problemType,
+		Title:         http.StatusText(e.HTTPStatusCode),
+		Status:        e.HTTPStatusCode,
+		Detail:        e.ErrorText,
+		Instance:      r.URL.RequestURI(),
+		Code:          e.AppCode,
+		Errors:        e.Details,
+		Meta:          e.Meta,
+		CorrelationID: e.CorrelationID,
+	}
+}
+
+// Respond is a render.Respond replacement that writes error responses as problem details
+// documents when the client prefers application/problem+json. Everything else, including
+// errors for clients that do not ask for problem details, goes through render.DefaultResponder.
+func Respond(w http.ResponseWriter, r *http.Request, v interface{}) {
+	e, ok := v.(*ErrResponse)
+	if !ok || !acceptsProblem(r) {
+		render.DefaultResponder(w, r, v)
+		return
+	}
+
+	body, err := json.Marshal(NewProblem(e, r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(e.HTTPStatusCode)
+	w.Write(body)
+}
+
+// acceptsProblem reports whether the Accept header ranks application/problem+json at least
+// as high as application/json. Wildcards alone keep the default JSON shape.
+func acceptsProblem(r *http.Request) bool {
+	problemQuality, jsonQuality := 0.0, 0.0
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case ProblemContentType:
+			problemQuality = max(problemQuality, quality)
+		case "application/json":
+			jsonQuality = max(jsonQuality, quality)
+		}
+	}
+
+	return problemQuality > 0 && problemQuality >= jsonQuality
+}
@@ -0,0 +1,52 @@
+package money
+
+import "fmt"
+
+// Currency is an ISO 4217 currency code, such as BRL.
+type Currency string
+
+// DefaultCurrency is used for accounts and transactions created without a currency.
+const DefaultCurrency Currency = "BRL"
+
+// exponents holds the number of minor unit decimal places of each supported currency.
+var exponents = map[Currency]int{
+	"ARS": 2,
+	"AUD": 2,
+	"BHD": 3,
+	"BRL": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CLP": 0,
+	"CNY": 2,
+	"COP": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MXN": 2,
+	"PEN": 2,
+	"PYG": 0,
+	"USD": 2,
+	"UYU": 2,
+}
+
+// ParseCurrency returns the currency for an ISO 4217 code, or an error when the currency
+// is not supported.
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(code)
+	if _, ok := exponents[c]; !ok {
+		return "", fmt.Errorf("currency %q is not supported", code)
+	}
+	return c, nil
+}
+
+// Exponent returns the number of decimal places of the currency's minor unit, such as 2
+// for BRL (cents) or 0 for JPY. Unknown currencies default to 2.
+func (c Currency) Exponent() int {
+	if exponent, ok := exponents[c]; ok {
+		return exponent
+	}
+	return 2
+}
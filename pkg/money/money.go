@@ -0,0 +1,180 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxScale is the largest number of decimal places a Decimal can hold.
+const maxScale = 18
+
+var (
+	// numberPattern is the JSON number grammar, with the exponent kept small
+	numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]{1,3})?$`)
+
+	ten = big.NewInt(10)
+)
+
+// MaxAmount is the largest amount, in minor units, accepted from clients, as amounts and limits
+// are stored in 32-bit integer columns.
+const MaxAmount Amount = math.MaxInt32
+
+// Amount is an exact monetary amount, stored as an integer number of minor units of its
+// currency (cents for BRL).
+type Amount int64
+
+// Abs returns the amount without its sign.
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Decimal is an exact decimal number, used to read and write amounts in JSON without going
+// through float64. It is read from either a JSON number or a string.
+type Decimal struct {
+	unscaled int64
+	scale    int
+}
+
+// ParseDecimal reads a decimal number such as "123.45", "0.29" or "1e2" exactly. The decimal
+// keeps the number of decimal places it was written with, so "50.00" formats back as "50.00".
+func ParseDecimal(s string) (Decimal, error) {
+	match := numberPattern.FindStringSubmatch(s)
+	if match == nil {
+		return Decimal{}, fmt.Errorf("amount %q is not a number", s)
+	}
+
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("amount %q is not a number", s)
+	}
+
+	scale := len(strings.TrimPrefix(match[2], "."))
+	if match[3] != "" {
+		exponent, _ := strconv.Atoi(match[3][1:])
+		scale = max(scale-exponent, 0)
+	}
+	if scale > maxScale {
+		return Decimal{}, fmt.Errorf("amount %q has too many decimal places", s)
+	}
+
+	value.Mul(value, new(big.Rat).SetInt(new(big.Int).Exp(ten, big.NewInt(int64(scale)), nil)))
+	if !value.IsInt() || !value.Num().IsInt64() {
+		return Decimal{}, fmt.Errorf("amount %q is out of range", s)
+	}
+
+	return Decimal{unscaled: value.Num().Int64(), scale: scale}, nil
+}
+
+// ToDecimal converts an amount in minor units of the currency into a Decimal with the
+// currency's number of decimal places.
+func ToDecimal(a Amount, c Currency) Decimal {
+	return Decimal{unscaled: int64(a), scale: c.Exponent()}
+}
+
+// MinorUnits converts the decimal into minor units of the currency. Decimals with more
+// significant decimal places than the currency has are rejected rather than rounded, and so
+// are amounts beyond MaxAmount either way.
+func (d Decimal) MinorUnits(c Currency) (Amount, error) {
+	exponent := c.Exponent()
+
+	// Trailing zeros beyond the currency exponent do not change the amount
+	units, scale := d.unscaled, d.scale
+	for scale > exponent && units%10 == 0 {
+		units /= 10
+		scale--
+	}
+	if scale > exponent {
+		return 0, fmt.Errorf("%s amounts must have at most %d decimal places", c, exponent)
+	}
+
+	for i := scale; i < exponent; i++ {
+		if units > math.MaxInt64/10 || units < math.MinInt64/10 {
+			return 0, errors.New("amount is out of range")
+		}
+		units *= 10
+	}
+	amount := Amount(units)
+	if amount.Abs() > MaxAmount {
+		return 0, fmt.Errorf("amount is out of range, at most %s %s", c, ToDecimal(MaxAmount, c))
+	}
+
+	return amount, nil
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the decimal.
+func (d Decimal) Sign() int {
+	switch {
+	case d.unscaled < 0:
+		return -1
+	case d.unscaled > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// String formats the decimal with all its decimal places, such as "-123.45".
+func (d Decimal) String() string {
+	sign := ""
+	units := uint64(d.unscaled)
+	if d.unscaled < 0 {
+		sign = "-"
+		units = uint64(-d.unscaled)
+	}
+
+	if d.scale == 0 {
+		return fmt.Sprintf("%s%d", sign, units)
+	}
+
+	pow := uint64(1)
+	for i := 0; i < d.scale; i++ {
+		pow *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/pow, d.scale, units%pow)
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("amount %s is not a string: %w", data, err)
+		}
+	}
+
+	decimal, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+
+	*d = decimal
+	return nil
+}
+
+// ValidationValue lets validator/v10 check Decimal fields with numeric tags such as
+// required and gt=0, by exposing the unscaled value, which has the same sign. Register it
+// with validate.RegisterCustomTypeFunc(money.ValidationValue, money.Decimal{}).
+func ValidationValue(field reflect.Value) interface{} {
+	if d, ok := field.Interface().(Decimal); ok {
+		return d.unscaled
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimal_MinorUnits(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		currency       Currency
+		expectedAmount Amount
+		expectErr      bool
+	}{
+		{name: "integer", input: "50", currency: "BRL", expectedAmount: 5000},
+		{name: "two decimals", input: "123.45", currency: "BRL", expectedAmount: 12345},
+		{name: "value that float64 truncates", input: "0.29", currency: "BRL", expectedAmount: 29},
+		{name: "value that float64 truncates above one", input: "1.15", currency: "BRL", expectedAmount: 115},
+		{name: "one decimal", input: "0.5", currency: "BRL", expectedAmount: 50},
+		{name: "trailing zeros", input: "10.500", currency: "BRL", expectedAmount: 1050},
+		{name: "negative", input: "-7.01", currency: "BRL", expectedAmount: -701},
+		{name: "exponent", input: "1.2345e2", currency: "BRL", expectedAmount: 12345},
+		{name: "zero decimal currency", input: "1500", currency: "JPY", expectedAmount: 1500},
+		{name: "three decimal currency", input: "1.234", currency: "KWD", expectedAmount: 1234},
+		{name: "three decimals", input: "0.291", currency: "BRL", expectErr: true},
+		{name: "decimals in zero decimal currency", input: "1500.5", currency: "JPY", expectErr: true},
+		{name: "leading zero", input: "01.00", currency: "BRL", expectErr: true},
+		{name: "fraction", input: "1/3", currency: "BRL", expectErr: true},
+		{name: "hexadecimal", input: "0x10", currency: "BRL", expectErr: true},
+		{name: "empty", input: "", currency: "BRL", expectErr: true},
+		{name: "out of range", input: "92233720368547758.08", currency: "BRL", expectErr: true},
+		{name: "largest amount", input: "21474836.47", currency: "BRL", expectedAmount: 2147483647},
+		{name: "above the largest amount", input: "21474836.48", currency: "BRL", expectErr: true},
+		{name: "below the smallest amount", input: "-21474836.48", currency: "BRL", expectErr: true},
+		{name: "largest amount in zero decimal currency", input: "2147483648", currency: "JPY", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decimal, err := ParseDecimal(tt.input)
+			var amount Amount
+			if err == nil {
+				amount, err = decimal.MinorUnits(tt.currency)
+			}
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got amount %d", amount)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if amount != tt.expectedAmount {
+				t.Errorf("expected amount %d, got %d", tt.expectedAmount, amount)
+			}
+		})
+	}
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedOutput string
+		expectErr      bool
+	}{
+		{name: "number", input: `0.29`, expectedOutput: `0.29`},
+		{name: "string", input: `"123.45"`, expectedOutput: `123.45`},
+		{name: "integer", input: `50`, expectedOutput: `50`},
+		{name: "negative", input: `-0.05`, expectedOutput: `-0.05`},
+		{name: "trailing zeros", input: `1.500`, expectedOutput: `1.500`},
+		{name: "exponent", input: `1.2345e2`, expectedOutput: `123.45`},
+		{name: "null", input: `null`, expectedOutput: `0`},
+		{name: "not a number", input: `"abc"`, expectErr: true},
+		{name: "boolean", input: `true`, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var decimal Decimal
+			err := json.Unmarshal([]byte(tt.input), &decimal)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", decimal)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			output, err := json.Marshal(decimal)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(output) != tt.expectedOutput {
+				t.Errorf("expected output %s, got %s", tt.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestToDecimal(t *testing.T) {
+	tests := []struct {
+		name           string
+		amount         Amount
+		currency       Currency
+		expectedOutput string
+	}{
+		{name: "cents", amount: 5000, currency: "BRL", expectedOutput: "50.00"},
+		{name: "negative cents", amount: -5, currency: "BRL", expectedOutput: "-0.05"},
+		{name: "zero decimal currency", amount: 1500, currency: "JPY", expectedOutput: "1500"},
+		{name: "three decimal currency", amount: 1234, currency: "KWD", expectedOutput: "1.234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if output := ToDecimal(tt.amount, tt.currency).String(); output != tt.expectedOutput {
+				t.Errorf("expected %s, got %s", tt.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		code      string
+		expectErr bool
+	}{
+		{code: "BRL"},
+		{code: "JPY"},
+		{code: "brl", expectErr: true},
+		{code: "XXX", expectErr: true},
+		{code: "", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			_, err := ParseCurrency(tt.code)
+			if tt.expectErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package validators
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/rikw22/challenge-money/pkg/money"
+)
+
+// Currency validates that a string field is a supported ISO 4217 currency code, such as BRL
+func Currency(fl validator.FieldLevel) bool {
+	_, err := money.ParseCurrency(fl.Field().String())
+	return err == nil
+}
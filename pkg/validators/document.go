@@ -0,0 +1,86 @@
+package validators
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Brazilian taxpayer document types
+const (
+	DocumentTypeCPF  = "CPF"
+	DocumentTypeCNPJ = "CNPJ"
+)
+
+var (
+	cpfWeights  = []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2}
+	cnpjWeights = []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+)
+
+// Document validates that a string field is a CPF or CNPJ with valid check digits,
+// with or without punctuation
+func Document(fl validator.FieldLevel) bool {
+	return DocumentType(NormalizeDocument(fl.Field().String())) != ""
+}
+
+// NormalizeDocument removes the punctuation of a formatted document, such as
+// 123.456.789-09 or 12.345.678/0001-95
+func NormalizeDocument(document string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '-', '/', ' ':
+			return -1
+		}
+		return r
+	}, document)
+}
+
+// DocumentType returns the type of a normalized document, or an empty string when it is
+// neither a valid CPF nor a valid CNPJ
+func DocumentType(document string) string {
+	switch {
+	case len(document) == 11 && hasValidCheckDigits(document, cpfWeights):
+		return DocumentTypeCPF
+	case len(document) == 14 && hasValidCheckDigits(document, cnpjWeights):
+		return DocumentTypeCNPJ
+	default:
+		return ""
+	}
+}
+
+// hasValidCheckDigits verifies the two trailing check digits of a document using the modulo 11
+// algorithm shared by CPF and CNPJ. Documents made of a single repeated digit pass the algorithm
+// but are not valid.
+func hasValidCheckDigits(document string, weights []int) bool {
+	digits := make([]int, len(document))
+	repeated := true
+	for i, r := range document {
+		if r < '0' || r > '9' {
+			return false
+		}
+		digits[i] = int(r - '0')
+		repeated = repeated && digits[i] == digits[0]
+	}
+	if repeated {
+		return false
+	}
+
+	for _, position := range []int{len(digits) - 2, len(digits) - 1} {
+		// The first check digit skips the leading weight, the second one uses all of them
+		offset := len(weights) - position
+		sum := 0
+		for i := 0; i < position; i++ {
+			sum += digits[i] * weights[i+offset]
+		}
+
+		checkDigit := 0
+		if remainder := sum % 11; remainder >= 2 {
+			checkDigit = 11 - remainder
+		}
+		if digits[position] != checkDigit {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,39 @@
+package validators
+
+import "testing"
+
+func TestDocumentType(t *testing.T) {
+	tests := []struct {
+		name         string
+		document     string
+		expectedType string
+	}{
+		{name: "valid cpf", document: "52998224725", expectedType: DocumentTypeCPF},
+		{name: "valid cpf with zero check digit", document: "12345678909", expectedType: DocumentTypeCPF},
+		{name: "valid formatted cpf", document: "111.444.777-35", expectedType: DocumentTypeCPF},
+		{name: "valid cnpj", document: "11222333000181", expectedType: DocumentTypeCNPJ},
+		{name: "valid formatted cnpj", document: "12.345.678/0001-95", expectedType: DocumentTypeCNPJ},
+		{name: "cpf with wrong first check digit", document: "52998224715", expectedType: ""},
+		{name: "cpf with wrong second check digit", document: "52998224726", expectedType: ""},
+		{name: "cnpj with wrong check digits", document: "11222333000182", expectedType: ""},
+		{name: "repeated digits", document: "11111111111", expectedType: ""},
+		{name: "letters", document: "5299822472a", expectedType: ""},
+		{name: "too short", document: "1234567890", expectedType: ""},
+		{name: "empty", document: "", expectedType: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documentType := DocumentType(NormalizeDocument(tt.document))
+			if documentType != tt.expectedType {
+				t.Errorf("expected document type %q, got %q", tt.expectedType, documentType)
+			}
+		})
+	}
+}
+
+func TestNormalizeDocument(t *testing.T) {
+	if normalized := NormalizeDocument("12.345.678/0001-95"); normalized != "12345678000195" {
+		t.Errorf("expected 12345678000195, got %s", normalized)
+	}
+}